@@ -0,0 +1,375 @@
+// Package admin implements an HTTP API to inspect and mutate HTTP and DNS routers at runtime.
+//
+// The API is a mountable `http.Handler`, e.g.
+//
+//	registry := config.NewRegistry()
+//	admin.RegisterBuiltins(registry, admin.AllowUpstreams("backend.internal:8080"))
+//	router.Handle(httpMux.Route{Path: "/admin/**"}, requireAuth(http.StripPrefix("/admin", admin.New(router, nil, registry))))
+//
+// in which paths are relative to the mount point:
+//
+//	GET    /{kind}/routes       lists registered routes with metadata
+//	GET    /{kind}/match        explains how a request is matched
+//	GET    /{kind}/entries      lists entries added by the API
+//	POST   /{kind}/entries      adds an entry, i.e. a `config.Entry` in JSON
+//	DELETE /{kind}/entries/{id} deletes an entry added by the API
+//
+// where kind is either "http" or "dns". The request to explain is given by query parameters,
+// `method`, `url` and repeated `header` in form of `Name: value` for HTTP, and `name`, `type`
+// and `class` for DNS.
+//
+// # Security
+//
+// The API performs no authentication, anyone reaching it can reroute traffic and read the route
+// table, so it MUST be mounted behind authentication and authorization, e.g. `requireAuth` above,
+// and never be exposed publicly. Entries are created only by factories of an explicit registry,
+// without which the API is read-only. Builtin factories touching files or networks are denied
+// unless allowed by `AllowDirs` and `AllowUpstreams`.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/vegertar/mux/config"
+	dnsMux "github.com/vegertar/mux/dns"
+	httpMux "github.com/vegertar/mux/http"
+	"github.com/vegertar/mux/x"
+)
+
+// Admin is an HTTP handler to inspect and mutate routers.
+type Admin struct {
+	router   *httpMux.Router
+	registry *config.Registry
+	http     *httpMux.Router
+	dns      *dnsMux.Router
+
+	mu      sync.Mutex
+	seq     int
+	entries map[string]*Entry
+}
+
+// Entry is an entry added by the API.
+type Entry struct {
+	// ID is the identity to delete the entry.
+	ID string `json:"id"`
+	// Kind is either "http" or "dns".
+	Kind string `json:"kind"`
+	config.Entry
+
+	close x.CloseFunc
+}
+
+// Route is a registered route.
+type Route struct {
+	Route string   `json:"route"`
+	Meta  []x.Meta `json:"meta,omitempty"`
+}
+
+// Match is the result of explaining a request.
+type Match struct {
+	// Matched tells if any registered handler handles the request.
+	Matched bool `json:"matched"`
+	// Route is the matched route if any, for DNS it's the first one of routes answering the question.
+	Route *Route `json:"route,omitempty"`
+	// Middleware are routes whose middleware wrap the handlers, from the outermost to the innermost.
+	Middleware []Route `json:"middleware,omitempty"`
+	// Allow, NotFound and Redirect are available for HTTP only, see `http.Explanation`.
+	Allow    []string `json:"allow,omitempty"`
+	NotFound *Route   `json:"not_found,omitempty"`
+	Redirect string   `json:"redirect,omitempty"`
+	// Steps are nodes visited in order.
+	Steps []Step `json:"steps"`
+}
+
+// Step is a node visited while matching a level, see `x.Step`.
+type Step struct {
+	Level      int         `json:"level"`
+	Path       [][]string  `json:"path"`
+	Key        []string    `json:"key"`
+	Fallback   string      `json:"fallback,omitempty"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Candidate is an edge tried while matching a level, see `x.Candidate`.
+type Candidate struct {
+	Key     []string `json:"key"`
+	Matched bool     `json:"matched"`
+	Order   int      `json:"order"`
+}
+
+// New creates an admin API of routers, either of which can be nil if not served. Entries are added by
+// factories in the registry, e.g. `RegisterBuiltins`, if nil, entries are not served, i.e. the API is
+// read-only. The API must be mounted behind authentication, see the package documentation.
+func New(httpRouter *httpMux.Router, dnsRouter *dnsMux.Router, registry *config.Registry) *Admin {
+	p := &Admin{
+		router:   httpMux.NewRouter(),
+		registry: registry,
+		http:     httpRouter,
+		dns:      dnsRouter,
+		entries:  make(map[string]*Entry),
+	}
+
+	if httpRouter != nil {
+		p.handle("GET", "/http/routes", p.httpRoutes)
+		p.handle("GET", "/http/match", p.httpMatch)
+		if registry != nil {
+			p.handleEntries("http", func(e config.Entry) (x.CloseFunc, error) {
+				return registry.AddHTTP(httpRouter, e)
+			})
+		}
+	}
+	if dnsRouter != nil {
+		p.handle("GET", "/dns/routes", p.dnsRoutes)
+		p.handle("GET", "/dns/match", p.dnsMatch)
+		if registry != nil {
+			p.handleEntries("dns", func(e config.Entry) (x.CloseFunc, error) {
+				return registry.AddDNS(dnsRouter, e)
+			})
+		}
+	}
+	return p
+}
+
+// ServeHTTP implements the `http.Handler` interface.
+func (p *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.router.ServeHTTP(w, r)
+}
+
+// Close deletes all entries added by the API.
+func (p *Admin) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, e := range p.entries {
+		e.close()
+		delete(p.entries, id)
+	}
+}
+
+// maxBodySize is the maximum size of request bodies, i.e. entries in JSON.
+const maxBodySize = 1 << 20
+
+func (p *Admin) handle(method, path string, f func(*http.Request) (int, interface{})) {
+	_, err := p.router.HandleFunc(httpMux.Route{Method: method, Path: path}, func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		code, v := f(r)
+		if err, ok := v.(error); ok {
+			v = struct {
+				Error string `json:"error"`
+			}{err.Error()}
+		}
+		writeJSON(w, code, v)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (p *Admin) handleEntries(kind string, add func(config.Entry) (x.CloseFunc, error)) {
+	p.handle("GET", "/"+kind+"/entries", func(r *http.Request) (int, interface{}) {
+		return http.StatusOK, p.list(kind)
+	})
+
+	p.handle("POST", "/"+kind+"/entries", func(r *http.Request) (int, interface{}) {
+		var e config.Entry
+		d := json.NewDecoder(r.Body)
+		d.DisallowUnknownFields()
+		if err := d.Decode(&e); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return http.StatusRequestEntityTooLarge, err
+			}
+			return http.StatusBadRequest, err
+		}
+		if e.Handler == nil && len(e.Middleware) == 0 {
+			return http.StatusBadRequest, errors.New("no handler or middleware")
+		}
+
+		closer, err := add(e)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.seq++
+		v := &Entry{ID: strconv.Itoa(p.seq), Kind: kind, Entry: e, close: closer}
+		p.entries[v.ID] = v
+		return http.StatusCreated, v
+	})
+
+	p.handle("DELETE", "/"+kind+"/entries/{id}", func(r *http.Request) (int, interface{}) {
+		id := httpMux.Vars(r).Get("id")
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		e, ok := p.entries[id]
+		if !ok || e.Kind != kind {
+			return http.StatusNotFound, errors.New("unknown entry " + strconv.Quote(id))
+		}
+		e.close()
+		delete(p.entries, id)
+		return http.StatusOK, e
+	})
+}
+
+// list returns entries of a kind in order of being added.
+func (p *Admin) list(kind string) []*Entry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]*Entry, 0, len(p.entries))
+	for _, e := range p.entries {
+		if e.Kind == kind {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := strconv.Atoi(out[i].ID)
+		b, _ := strconv.Atoi(out[j].ID)
+		return a < b
+	})
+	return out
+}
+
+func (p *Admin) httpRoutes(r *http.Request) (int, interface{}) {
+	routes := p.http.Routes()
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		routes = p.http.RoutesByTag(tag)
+	}
+
+	out := make([]Route, 0, len(routes))
+	for _, v := range routes {
+		out = append(out, Route{v.String(), v.Meta})
+	}
+	return http.StatusOK, out
+}
+
+func (p *Admin) dnsRoutes(r *http.Request) (int, interface{}) {
+	routes := p.dns.Routes()
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		routes = p.dns.RoutesByTag(tag)
+	}
+
+	out := make([]Route, 0, len(routes))
+	for _, v := range routes {
+		out = append(out, Route{v.String(), v.Meta})
+	}
+	return http.StatusOK, out
+}
+
+func (p *Admin) httpMatch(r *http.Request) (int, interface{}) {
+	q := r.URL.Query()
+	method := q.Get("method")
+	if method == "" {
+		method = "GET"
+	}
+	req, err := http.NewRequest(method, q.Get("url"), nil)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if req.URL.Host == "" {
+		return http.StatusBadRequest, errors.New("url requires a host")
+	}
+	for _, h := range q["header"] {
+		i := strings.IndexByte(h, ':')
+		if i <= 0 {
+			return http.StatusBadRequest, errors.New("malformed header " + strconv.Quote(h))
+		}
+		req.Header.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+	}
+
+	e, err := p.http.Explain(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	m := Match{
+		Allow:    e.Allow,
+		Redirect: e.Redirect,
+		Steps:    newSteps(e.Steps),
+	}
+	if e.Handler != nil {
+		m.Matched = true
+		m.Route = &Route{e.Handler.String(), e.Handler.Meta}
+	}
+	if e.NotFound != nil {
+		m.NotFound = &Route{e.NotFound.String(), e.NotFound.Meta}
+	}
+	for _, v := range e.Middleware {
+		m.Middleware = append(m.Middleware, Route{v.String(), v.Meta})
+	}
+	return http.StatusOK, m
+}
+
+func (p *Admin) dnsMatch(r *http.Request) (int, interface{}) {
+	q := r.URL.Query()
+	qtype, qclass := dns.TypeA, uint16(dns.ClassINET)
+	if s := q.Get("type"); s != "" {
+		v, ok := dns.StringToType[strings.ToUpper(s)]
+		if !ok {
+			return http.StatusBadRequest, errors.New("unknown type " + strconv.Quote(s))
+		}
+		qtype = v
+	}
+	if s := q.Get("class"); s != "" {
+		v, ok := dns.StringToClass[strings.ToUpper(s)]
+		if !ok {
+			return http.StatusBadRequest, errors.New("unknown class " + strconv.Quote(s))
+		}
+		qclass = v
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(q.Get("name")), qtype)
+	msg.Question[0].Qclass = qclass
+
+	e, err := p.dns.Explain(msg)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	m := Match{Steps: newSteps(e.Steps)}
+	if len(e.Handlers) > 0 {
+		m.Matched = true
+		m.Route = &Route{e.Handlers[0].String(), e.Handlers[0].Meta}
+	}
+	for _, v := range e.Middleware {
+		m.Middleware = append(m.Middleware, Route{v.String(), v.Meta})
+	}
+	return http.StatusOK, m
+}
+
+func newSteps(steps []x.Step) []Step {
+	out := make([]Step, 0, len(steps))
+	for _, v := range steps {
+		step := Step{
+			Level:      v.Level,
+			Path:       make([][]string, 0, len(v.Path)),
+			Key:        v.Key.Strings(),
+			Fallback:   v.Fallback,
+			Candidates: make([]Candidate, 0, len(v.Candidates)),
+		}
+		for _, k := range v.Path {
+			step.Path = append(step.Path, k.Strings())
+		}
+		for _, c := range v.Candidates {
+			step.Candidates = append(step.Candidates, Candidate{c.Key.Strings(), c.Matched, c.Order})
+		}
+		out = append(out, step)
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,287 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/vegertar/mux/config"
+	dnsMux "github.com/vegertar/mux/dns"
+	httpMux "github.com/vegertar/mux/http"
+)
+
+// dnsRecorder records the written message.
+type dnsRecorder struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (w *dnsRecorder) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+}
+
+func (w *dnsRecorder) WriteMsg(msg *dns.Msg) error {
+	w.msg = msg
+	return nil
+}
+
+func call(t *testing.T, h http.Handler, method, target, body string, v interface{}) int {
+	t.Helper()
+
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request)
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, target, err, w.Body.String())
+		}
+	}
+	return w.Code
+}
+
+func newTestRegistry(opts ...BuiltinOption) *config.Registry {
+	registry := config.NewRegistry()
+	RegisterBuiltins(registry, opts...)
+	return registry
+}
+
+func TestAdmin_HTTP(t *testing.T) {
+	dir := t.TempDir()
+	router := httpMux.NewRouter()
+	admin := New(router, nil, newTestRegistry(AllowDirs(dir), AllowUpstreams("backend.internal:8080")))
+	_, err := router.Handle(httpMux.Route{Path: "/admin/**"}, http.StripPrefix("/admin", admin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e Entry
+	code := call(t, router, "POST", "/admin/http/entries", `{
+		"route": "GET *://example.com/hello",
+		"handler": {"name": "static", "args": {"body": "hello", "status": "201"}},
+		"name": "hello",
+		"tags": ["greeting"]
+	}`, &e)
+	if code != http.StatusCreated || e.ID == "" || e.Kind != "http" || e.Name != "hello" {
+		t.Fatalf("unexpected entry %d %+v", code, e)
+	}
+
+	request := httptest.NewRequest("GET", "http://example.com/hello", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	if w.Code != http.StatusCreated || w.Body.String() != "hello" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	var routes []Route
+	if code := call(t, router, "GET", "/admin/http/routes?tag=greeting", "", &routes); code != http.StatusOK {
+		t.Fatalf("unexpected code %d", code)
+	}
+	if len(routes) != 1 || routes[0].Route != "GET *://example.com:*/hello" || routes[0].Meta[0].Name != "hello" {
+		t.Fatalf("unexpected routes %+v", routes)
+	}
+
+	cases := []struct {
+		method, url string
+		matched     bool
+	}{
+		{"GET", "http://example.com/hello", true},
+		{"POST", "http://example.com/hello", false},
+		{"GET", "https://example.com/hello", true},
+		{"GET", "http://example.org/hello", false},
+	}
+	for i, c := range cases {
+		var m Match
+		q := url.Values{"method": {c.method}, "url": {c.url}}
+		if code := call(t, router, "GET", "/admin/http/match?"+q.Encode(), "", &m); code != http.StatusOK {
+			t.Fatalf("bad case %d: unexpected code %d", i+1, code)
+		}
+		if m.Matched != c.matched {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.matched, m.Matched)
+		}
+		if m.Matched && m.Route.Route != routes[0].Route {
+			t.Errorf("bad case %d: expected %q, got %q", i+1, routes[0].Route, m.Route.Route)
+		}
+	}
+
+	var entries []Entry
+	if call(t, router, "GET", "/admin/http/entries", "", &entries); len(entries) != 1 || entries[0].ID != e.ID {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if code := call(t, router, "DELETE", "/admin/http/entries/"+e.ID, "", nil); code != http.StatusOK {
+		t.Fatalf("unexpected code %d", code)
+	}
+	if code := call(t, router, "DELETE", "/admin/http/entries/"+e.ID, "", nil); code != http.StatusNotFound {
+		t.Fatalf("unexpected code %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+
+	errorCases := []string{
+		`{"route": "GET *://example.com/x"}`,
+		`{"route": "GET *://example.com/x", "handler": {"name": "unknown"}}`,
+		`{"route": "GET *://example.com/x", "handler": {"name": "redirect", "args": {"url": "/", "status": "200"}}}`,
+		`{"route": "GET *://example.com/x", "handler": {"name": "proxy", "args": {"url": "/relative"}}}`,
+		`{"route": "GET", "handler": {"name": "static", "args": {"body": ""}}}`,
+		`{"route": "GET *://example.com/x", "handler": {"name": "static", "args": {"dir": "/"}}}`,
+		`{"route": "GET *://example.com/x", "handler": {"name": "static", "args": {"dir": "` + dir + `/.."}}}`,
+		`{"route": "GET *://example.com/x", "handler": {"name": "proxy", "args": {"url": "http://169.254.169.254/"}}}`,
+		`{"route": "GET *://example.com/x", "handler": {"name": "proxy", "args": {"url": "http://backend.internal/"}}}`,
+	}
+	for i, body := range errorCases {
+		if code := call(t, router, "POST", "/admin/http/entries", body, nil); code != http.StatusBadRequest {
+			t.Errorf("bad case %d: expected 400, got %d", i+1, code)
+		}
+	}
+	large := `{"route": "GET *://example.com/x", "description": "` + strings.Repeat("x", maxBodySize) + `"}`
+	if code := call(t, router, "POST", "/admin/http/entries", large, nil); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a large body, got %d", code)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"route": "GET *://example.com/static/**", "handler": {"name": "static", "args": {"dir": "` + dir + `", "prefix": "/static"}}}`
+	if code := call(t, router, "POST", "/admin/http/entries", body, nil); code != http.StatusCreated {
+		t.Fatalf("unexpected code %d", code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/static/a.txt", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "a" {
+		t.Errorf("unexpected static file %d %q", w.Code, w.Body.String())
+	}
+
+	okCases := []string{
+		`{"route": "GET *://example.com/x", "handler": {"name": "static", "args": {"dir": "` + dir + `/sub"}}}`,
+		`{"route": "GET *://example.com/y", "handler": {"name": "proxy", "args": {"url": "http://backend.internal:8080/api"}}}`,
+	}
+	for i, body := range okCases {
+		if code := call(t, router, "POST", "/admin/http/entries", body, nil); code != http.StatusCreated {
+			t.Errorf("bad case %d: expected 201, got %d", i+1, code)
+		}
+	}
+	if code := call(t, router, "GET", "/admin/dns/routes", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a DNS router, got %d", code)
+	}
+}
+
+func TestAdmin_ReadOnly(t *testing.T) {
+	router := httpMux.NewRouter()
+	admin := New(router, nil, nil)
+
+	if code := call(t, admin, "GET", "/http/routes", "", nil); code != http.StatusOK {
+		t.Fatalf("unexpected code %d", code)
+	}
+	body := `{"route": "GET *://example.com/x", "handler": {"name": "static", "args": {"body": "x"}}}`
+	if code := call(t, admin, "POST", "/http/entries", body, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a registry, got %d", code)
+	}
+	if n := len(router.Routes()); n != 0 {
+		t.Fatalf("expected no routes, got %d", n)
+	}
+
+	// builtins deny files and networks by default
+	admin = New(router, nil, newTestRegistry())
+	for i, body := range []string{
+		`{"route": "GET *://example.com/x", "handler": {"name": "static", "args": {"dir": "."}}}`,
+		`{"route": "GET *://example.com/x", "handler": {"name": "proxy", "args": {"url": "http://example.org/"}}}`,
+	} {
+		if code := call(t, admin, "POST", "/http/entries", body, nil); code != http.StatusBadRequest {
+			t.Errorf("bad case %d: expected 400, got %d", i+1, code)
+		}
+	}
+}
+
+func TestAdmin_DNS(t *testing.T) {
+	router := dnsMux.NewRouter()
+	admin := New(nil, router, newTestRegistry(AllowUpstreams("192.0.2.53:53")))
+	defer admin.Close()
+
+	query := func(name string, qtype uint16) *dns.Msg {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, qtype)
+		w := new(dnsRecorder)
+		router.ServeFunc(context.Background())(w, msg)
+		return w.msg
+	}
+
+	var e Entry
+	code := call(t, admin, "POST", "/dns/entries", `{
+		"route": "www.example.com CNAME",
+		"handler": {"name": "redirect", "args": {"target": "example.com"}}
+	}`, &e)
+	if code != http.StatusCreated {
+		t.Fatalf("unexpected code %d", code)
+	}
+	code = call(t, admin, "POST", "/dns/entries", `{
+		"route": "example.com A",
+		"handler": {"name": "static", "args": {"rr": "example.com. 60 IN A 192.0.2.1"}}
+	}`, nil)
+	if code != http.StatusCreated {
+		t.Fatalf("unexpected code %d", code)
+	}
+
+	msg := query("www.example.com.", dns.TypeA)
+	if msg == nil || len(msg.Answer) != 2 {
+		t.Fatalf("unexpected answer %v", msg)
+	}
+	if cname, ok := msg.Answer[0].(*dns.CNAME); !ok || cname.Target != "example.com." {
+		t.Errorf("expected CNAME to example.com., got %v", msg.Answer[0])
+	}
+	if a, ok := msg.Answer[1].(*dns.A); !ok || a.A.String() != "192.0.2.1" {
+		t.Errorf("expected A of 192.0.2.1, got %v", msg.Answer[1])
+	}
+
+	var m Match
+	if call(t, admin, "GET", "/dns/match?name=www.example.com", "", &m); !m.Matched || m.Route.Route != "www.example.com CNAME IN" {
+		t.Fatalf("unexpected match %+v", m)
+	}
+	if step := m.Steps[len(m.Steps)-1]; step.Fallback != "CNAME" || !step.Candidates[0].Matched {
+		t.Fatalf("unexpected step %+v", step)
+	}
+	if call(t, admin, "GET", "/dns/match?name=example.org&type=aaaa", "", &m); m.Matched {
+		t.Fatalf("unexpected match %+v", m)
+	}
+	if code := call(t, admin, "GET", "/dns/match?name=example.org&type=bad", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+
+	for i, c := range []struct {
+		addr string
+		code int
+	}{
+		{"192.0.2.53:53", http.StatusCreated},
+		{"8.8.8.8:53", http.StatusBadRequest},
+	} {
+		body := `{"route": "example.net", "handler": {"name": "proxy", "args": {"addr": "` + c.addr + `"}}}`
+		var v Entry
+		if code := call(t, admin, "POST", "/dns/entries", body, &v); code != c.code {
+			t.Errorf("bad case %d: expected %d, got %d", i+1, c.code, code)
+		} else if code == http.StatusCreated {
+			call(t, admin, "DELETE", "/dns/entries/"+v.ID, "", nil)
+		}
+	}
+
+	var routes []Route
+	if call(t, admin, "GET", "/dns/routes", "", &routes); len(routes) != 2 {
+		t.Fatalf("unexpected routes %+v", routes)
+	}
+
+	if code := call(t, admin, "DELETE", "/dns/entries/"+e.ID, "", nil); code != http.StatusOK {
+		t.Fatalf("unexpected code %d", code)
+	}
+	if call(t, admin, "GET", "/dns/match?name=www.example.com", "", &m); m.Matched {
+		t.Fatalf("unexpected match %+v", m)
+	}
+}
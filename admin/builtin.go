@@ -0,0 +1,239 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/vegertar/mux/config"
+	dnsMux "github.com/vegertar/mux/dns"
+)
+
+type (
+	// BuiltinOption restricts arguments of builtin factories.
+	BuiltinOption func(*builtinOptions)
+
+	builtinOptions struct {
+		dirs      []string
+		upstreams []string
+	}
+)
+
+// AllowDirs allows HTTP "static" to serve files in the given directories and their subdirectories,
+// no directory is allowed by default. Note that symbolic links inside them are followed.
+func AllowDirs(dirs ...string) BuiltinOption {
+	return func(o *builtinOptions) {
+		o.dirs = append(o.dirs, dirs...)
+	}
+}
+
+// AllowUpstreams allows "proxy" to forward to the given addresses, which are compared with hosts of
+// URLs for HTTP, e.g. `backend.internal:8080`, and `addr` for DNS, e.g. `8.8.8.8:53`. No upstream
+// is allowed by default.
+func AllowUpstreams(addrs ...string) BuiltinOption {
+	return func(o *builtinOptions) {
+		o.upstreams = append(o.upstreams, addrs...)
+	}
+}
+
+// RegisterBuiltins registers builtin handler factories into a registry, including
+//
+//	HTTP "proxy"    forwards requests to `url` of an allowed upstream
+//	HTTP "static"   serves files in `dir` of an allowed one, or replies `body` with optional `content_type` and `status`
+//	HTTP "redirect" redirects requests to `url` with optional `status`, which is 302 by default
+//	DNS  "proxy"    forwards questions to the server at `addr` of an allowed upstream, e.g. `8.8.8.8:53`
+//	DNS  "static"   answers records in zone file format in `rr`, one per line
+//	DNS  "redirect" answers a CNAME record to `target` with optional `ttl`, which is 300 by default
+//
+// The "static" handler resolves the full request path under `dir`, e.g. `/static/a.css` of an entry on
+// `/static/**` is `<dir>/static/a.css`, unless an optional `prefix` is given to strip, e.g. `/static`.
+func RegisterBuiltins(r *config.Registry, opts ...BuiltinOption) {
+	o := new(builtinOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	r.HTTPHandler("proxy", o.newHTTPProxy)
+	r.HTTPHandler("static", o.newHTTPStatic)
+	r.HTTPHandler("redirect", newHTTPRedirect)
+	r.DNSHandler("proxy", o.newDNSProxy)
+	r.DNSHandler("static", newDNSStatic)
+	r.DNSHandler("redirect", newDNSRedirect)
+}
+
+// allowDir returns an error if the directory is outside of allowed ones.
+func (o *builtinOptions) allowDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for _, v := range o.dirs {
+		base, err := filepath.Abs(v)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(base, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return errors.New("dir " + strconv.Quote(dir) + " is not allowed")
+}
+
+// allowUpstream returns an error if the address is not an allowed upstream.
+func (o *builtinOptions) allowUpstream(addr string) error {
+	for _, v := range o.upstreams {
+		if strings.EqualFold(v, addr) {
+			return nil
+		}
+	}
+	return errors.New("upstream " + strconv.Quote(addr) + " is not allowed")
+}
+
+func (o *builtinOptions) newHTTPProxy(args map[string]string) (http.Handler, error) {
+	u, err := url.Parse(args["url"])
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("proxy requires an absolute url")
+	}
+	if err := o.allowUpstream(u.Host); err != nil {
+		return nil, err
+	}
+	return httputil.NewSingleHostReverseProxy(u), nil
+}
+
+func (o *builtinOptions) newHTTPStatic(args map[string]string) (http.Handler, error) {
+	if dir, ok := args["dir"]; ok {
+		if err := o.allowDir(dir); err != nil {
+			return nil, err
+		}
+		h := http.FileServer(http.Dir(dir))
+		if prefix := args["prefix"]; prefix != "" {
+			h = http.StripPrefix(strings.TrimSuffix(prefix, "/"), h)
+		}
+		return h, nil
+	}
+
+	body, ok := args["body"]
+	if !ok {
+		return nil, errors.New("static requires a dir or body")
+	}
+	status, err := statusArg(args, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	contentType := args["content_type"]
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}), nil
+}
+
+func newHTTPRedirect(args map[string]string) (http.Handler, error) {
+	to := args["url"]
+	if to == "" {
+		return nil, errors.New("redirect requires a url")
+	}
+	status, err := statusArg(args, http.StatusFound)
+	if err != nil {
+		return nil, err
+	}
+	if status < 300 || status > 399 {
+		return nil, errors.New("invalid redirect status " + strconv.Itoa(status))
+	}
+	return http.RedirectHandler(to, status), nil
+}
+
+func statusArg(args map[string]string, defaultStatus int) (int, error) {
+	s, ok := args["status"]
+	if !ok {
+		return defaultStatus, nil
+	}
+	return strconv.Atoi(s)
+}
+
+func (o *builtinOptions) newDNSProxy(args map[string]string) (dnsMux.Handler, error) {
+	addr := args["addr"]
+	if addr == "" {
+		return nil, errors.New("proxy requires an addr")
+	}
+	if err := o.allowUpstream(addr); err != nil {
+		return nil, err
+	}
+	return dnsMux.HandlerFunc(func(w dnsMux.ResponseWriter, r *dnsMux.Request) {
+		in, err := dns.ExchangeContext(r.Context(), r.Msg, addr)
+		if err != nil {
+			dnsMux.FailureErrorHandler.ServeDNS(w, r)
+			return
+		}
+		w.Header().Rcode = in.Rcode
+		w.Answer(in.Answer...)
+		w.Ns(in.Ns...)
+		w.Extra(in.Extra...)
+		w.WriteMsg(r.Msg)
+	}), nil
+}
+
+func newDNSStatic(args map[string]string) (dnsMux.Handler, error) {
+	var rrs []dns.RR
+	for _, line := range strings.Split(args["rr"], "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rr, err := dns.NewRR(line)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+	if len(rrs) == 0 {
+		return nil, errors.New("static requires records")
+	}
+	return answer(rrs...), nil
+}
+
+func newDNSRedirect(args map[string]string) (dnsMux.Handler, error) {
+	target := args["target"]
+	if target == "" {
+		return nil, errors.New("redirect requires a target")
+	}
+	ttl := uint64(300)
+	if s, ok := args["ttl"]; ok {
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ttl = v
+	}
+
+	return dnsMux.HandlerFunc(func(w dnsMux.ResponseWriter, r *dnsMux.Request) {
+		rr := new(dns.CNAME)
+		rr.Hdr = dns.RR_Header{
+			Name:   r.Question[0].Name,
+			Rrtype: dns.TypeCNAME,
+			Class:  r.Question[0].Qclass,
+			Ttl:    uint32(ttl),
+		}
+		rr.Target = dns.Fqdn(target)
+		answer(rr).ServeDNS(w, r)
+	}), nil
+}
+
+// answer replies the given records.
+func answer(rrs ...dns.RR) dnsMux.Handler {
+	return dnsMux.HandlerFunc(func(w dnsMux.ResponseWriter, r *dnsMux.Request) {
+		w.Answer(rrs...)
+		w.WriteMsg(r.Msg)
+	})
+}
@@ -0,0 +1,119 @@
+// Package config loads HTTP and DNS route tables from JSON or YAML files,
+// handlers and middleware are created by named factories in a registry.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vegertar/mux/x"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Config is a route table.
+	Config struct {
+		// HTTP are entries of HTTP routes, in which routes are parsed by `http.ParseRoute`.
+		HTTP []Entry `json:"http,omitempty" yaml:"http,omitempty"`
+		// DNS are entries of DNS routes, in which routes are parsed by `dns.ParseRoute`.
+		DNS []Entry `json:"dns,omitempty" yaml:"dns,omitempty"`
+	}
+
+	// Entry associates a route with a handler and middleware.
+	Entry struct {
+		// Route is the string representation of a route, e.g. `GET *://api.example.com/users/{id:int}`.
+		Route string `json:"route" yaml:"route"`
+		// Handler is the handler, which can be omitted to add middleware only.
+		Handler *Component `json:"handler,omitempty" yaml:"handler,omitempty"`
+		// Middleware are added in order.
+		Middleware []Component `json:"middleware,omitempty" yaml:"middleware,omitempty"`
+
+		// Name, Tags, Owner and Description are metadata of the handler and middleware.
+		Name        string   `json:"name,omitempty" yaml:"name,omitempty"`
+		Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+		Owner       string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+		Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	}
+
+	// Component refers to a factory by name, along with arguments passed to the factory.
+	Component struct {
+		Name string            `json:"name" yaml:"name"`
+		Args map[string]string `json:"args,omitempty" yaml:"args,omitempty"`
+	}
+)
+
+// Parse decodes a config in the given format, i.e. "json" or "yaml".
+func Parse(r io.Reader, format string) (*Config, error) {
+	c := new(Config)
+	switch strings.ToLower(format) {
+	case "json":
+		d := json.NewDecoder(r)
+		d.DisallowUnknownFields()
+		if err := d.Decode(c); err != nil {
+			return nil, err
+		}
+	case "yaml", "yml":
+		d := yaml.NewDecoder(r)
+		d.KnownFields(true)
+		if err := d.Decode(c); err != nil && err != io.EOF {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return c, nil
+}
+
+// ParseFile decodes a config file, the format is decided by the file extension.
+func ParseFile(name string) (*Config, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := Parse(f, strings.TrimPrefix(filepath.Ext(name), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
+
+// meta returns the metadata of the entry, or nil if absent.
+func (e Entry) meta() *x.Meta {
+	if e.Name == "" && len(e.Tags) == 0 && e.Owner == "" && e.Description == "" {
+		return nil
+	}
+	return &x.Meta{
+		Name:        e.Name,
+		Tags:        e.Tags,
+		Owner:       e.Owner,
+		Description: e.Description,
+	}
+}
+
+// middlewareKey returns the identity of the middleware part, by which changes are detected.
+func (e Entry) middlewareKey() string {
+	v := struct {
+		Middleware []Component
+		Meta       *x.Meta
+	}{Middleware: e.Middleware}
+	if e.Handler == nil {
+		v.Meta = e.meta()
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// handlerKey returns the identity of the handler part, by which changes are detected.
+func (e Entry) handlerKey() string {
+	b, _ := json.Marshal(struct {
+		Handler *Component
+		Meta    *x.Meta
+	}{e.Handler, e.meta()})
+	return string(b)
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	dnsMux "github.com/vegertar/mux/dns"
+	httpMux "github.com/vegertar/mux/http"
+)
+
+func newTestLoader(created map[string]int) *Loader {
+	registry := NewRegistry()
+	registry.HTTPHandler("text", func(args map[string]string) (http.Handler, error) {
+		created[args["body"]]++
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(args["body"]))
+		}), nil
+	})
+	registry.HTTPMiddleware("header", func(args map[string]string) (httpMux.Middleware, error) {
+		return httpMux.MiddlewareFunc(func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(args["name"], args["value"])
+				h.ServeHTTP(w, r)
+			})
+		}), nil
+	})
+	registry.DNSHandler("a", func(args map[string]string) (dnsMux.Handler, error) {
+		return dnsMux.HandlerFunc(func(w dnsMux.ResponseWriter, r *dnsMux.Request) {
+			a := new(dns.A)
+			a.Hdr.Name = args["name"]
+			w.Answer(a)
+		}), nil
+	})
+
+	return &Loader{
+		Registry: registry,
+		HTTP:     httpMux.NewRouter(),
+		DNS:      dnsMux.NewRouter(),
+	}
+}
+
+func serveHTTP(router *httpMux.Router, path string) (string, http.Header, int) {
+	request := httptest.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	return w.Body.String(), w.Header(), w.Code
+}
+
+func TestLoader_Load(t *testing.T) {
+	created := make(map[string]int)
+	loader := newTestLoader(created)
+
+	c, err := Parse(strings.NewReader(`
+http:
+  - route: GET *://**/users
+    handler: {name: text, args: {body: users}}
+    name: users.list
+    tags: [users]
+  - route: "* *://**/groups"
+    handler: {name: text, args: {body: groups}}
+  - route: "* *://**/**"
+    middleware:
+      - {name: header, args: {name: X-Test, value: "1"}}
+dns:
+  - route: example.com A IN
+    handler: {name: a, args: {name: example}}
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Load(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if body, header, _ := serveHTTP(loader.HTTP, "/users"); body != "users" || header.Get("X-Test") != "1" {
+		t.Errorf("bad users: %q %v", body, header)
+	}
+	if body, _, _ := serveHTTP(loader.HTTP, "/groups"); body != "groups" {
+		t.Errorf("bad groups: %q", body)
+	}
+	if routes := loader.HTTP.RoutesByTag("users"); len(routes) != 1 {
+		t.Errorf("expected 1 route tagged users, got %v", routes)
+	}
+
+	if h := loader.DNS.Match(dnsMux.Route{Name: "example.com", UseLiteral: true}); h == nil {
+		t.Errorf("expected a DNS handler")
+	}
+	if n := len(loader.DNS.Routes()); n != 1 {
+		t.Errorf("expected 1 DNS route, got %d", n)
+	}
+
+	// changes groups, removes the middleware, and keeps users as is
+	c.HTTP[1].Handler.Args = map[string]string{"body": "teams"}
+	c.HTTP = c.HTTP[:2]
+	if err := loader.Load(c); err != nil {
+		t.Fatal(err)
+	}
+	if body, header, _ := serveHTTP(loader.HTTP, "/users"); body != "users" || header.Get("X-Test") != "" {
+		t.Errorf("bad users after reloaded: %q %v", body, header)
+	}
+	if body, _, _ := serveHTTP(loader.HTTP, "/groups"); body != "teams" {
+		t.Errorf("bad groups after reloaded: %q", body)
+	}
+	if n := created["users"]; n != 1 {
+		t.Errorf("expected unchanged entry created once, got %d", n)
+	}
+
+	// a bad entry changes nothing
+	bad := *c
+	bad.HTTP = append([]Entry{{Route: "GET *://**/new", Handler: &Component{Name: "text", Args: map[string]string{"body": "new"}}}},
+		Entry{Route: "GET *://**/bad", Handler: &Component{Name: "unknown"}})
+	if err := loader.Load(&bad); err == nil {
+		t.Errorf("expected an error of unknown handler")
+	}
+	if _, _, code := serveHTTP(loader.HTTP, "/new"); code != 404 {
+		t.Errorf("expected new route rolled back, got %d", code)
+	}
+	if body, _, _ := serveHTTP(loader.HTTP, "/users"); body != "users" {
+		t.Errorf("expected users kept after failed, got %q", body)
+	}
+
+	loader.Close()
+	if n := len(loader.HTTP.Routes()) + len(loader.DNS.Routes()); n != 0 {
+		t.Errorf("expected no routes after closed, got %d", n)
+	}
+}
+
+func TestLoader_Swap(t *testing.T) {
+	loader := newTestLoader(make(map[string]int))
+
+	var probed [][]string
+	loader.Registry.HTTPHandler("probe", func(args map[string]string) (http.Handler, error) {
+		// records metadata of the groups route while the config is being loaded
+		var names []string
+		for _, r := range loader.HTTP.Routes() {
+			if r.Path == "/groups" {
+				for _, meta := range r.Meta {
+					names = append(names, meta.Name)
+				}
+			}
+		}
+		probed = append(probed, names)
+		return http.NotFoundHandler(), nil
+	})
+
+	groups := Entry{Route: "* *://**/groups", Handler: &Component{Name: "text", Args: map[string]string{"body": "groups"}}, Name: "groups"}
+	teams := Entry{Route: "* *://**/groups", Handler: &Component{Name: "text", Args: map[string]string{"body": "teams"}}, Name: "teams"}
+	probe := Entry{Route: "* *://**/probe", Handler: &Component{Name: "probe"}}
+	if err := loader.Load(&Config{HTTP: []Entry{groups}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Load(&Config{HTTP: []Entry{teams, probe}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(probed) != 1 || len(probed[0]) != 1 || probed[0][0] != "teams" {
+		t.Errorf("expected the handler swapped in place, got %v", probed)
+	}
+	if body, _, _ := serveHTTP(loader.HTTP, "/groups"); body != "teams" {
+		t.Errorf("bad groups after swapped: %q", body)
+	}
+
+	// a bad entry swaps back
+	bad := Entry{Route: "GET *://**/bad", Handler: &Component{Name: "unknown"}}
+	if err := loader.Load(&Config{HTTP: []Entry{groups, bad}}); err == nil {
+		t.Errorf("expected an error of unknown handler")
+	}
+	if body, _, _ := serveHTTP(loader.HTTP, "/groups"); body != "teams" {
+		t.Errorf("expected teams swapped back, got %q", body)
+	}
+	for _, r := range loader.HTTP.Routes() {
+		if r.Path == "/groups" && (len(r.Meta) != 1 || r.Meta[0].Name != "teams") {
+			t.Errorf("expected metadata swapped back, got %v", r.Meta)
+		}
+	}
+
+	// removing the probe keeps the swapped handler
+	if err := loader.Load(&Config{HTTP: []Entry{teams}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, code := serveHTTP(loader.HTTP, "/probe"); code != 404 {
+		t.Errorf("expected probe closed, got %d", code)
+	}
+	if body, _, _ := serveHTTP(loader.HTTP, "/groups"); body != "teams" {
+		t.Errorf("bad groups after probe removed: %q", body)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "routes.json")
+	err := os.WriteFile(name, []byte(`{"http": [{"route": "GET *://**/", "handler": {"name": "text"}, "owner": "team-a"}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ParseFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.HTTP) != 1 || c.HTTP[0].Owner != "team-a" || c.HTTP[0].Handler.Name != "text" {
+		t.Errorf("bad config: %+v", c)
+	}
+
+	if _, err := Parse(strings.NewReader(`{"unknown": 1}`), "json"); err == nil {
+		t.Errorf("expected an error of unknown fields")
+	}
+	if _, err := Parse(strings.NewReader(``), "toml"); err == nil {
+		t.Errorf("expected an error of unknown format")
+	}
+}
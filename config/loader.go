@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	dnsMux "github.com/vegertar/mux/dns"
+	httpMux "github.com/vegertar/mux/http"
+	"github.com/vegertar/mux/x"
+)
+
+// Loader registers entries of configs into routers, and keeps the registrations
+// so that reloading a config applies only the changes.
+type Loader struct {
+	// Registry holds factories referred by entries.
+	Registry *Registry
+	// HTTP is the router of HTTP entries.
+	HTTP *httpMux.Router
+	// DNS is the router of DNS entries.
+	DNS *dnsMux.Router
+
+	mu     sync.Mutex
+	loaded []*loadedEntry
+}
+
+// loadedEntry is an entry registered by a `Loader`.
+type loadedEntry struct {
+	*registration
+
+	// id is the kind, the route and the occurrence of the route, by which entries are paired on reloading.
+	id string
+	// keys of parts are computed on loading since entries might be modified by callers later.
+	middlewareKey, handlerKey string
+}
+
+// Load applies a config, in which new entries are added, removed entries are closed and unchanged
+// entries are kept as is. Entries are paired by routes in order, the handler of a changed entry is
+// swapped in place, so there is neither a gap nor a duplicate of it, while changed middleware is
+// added before the old one is closed. If any entry fails, the error is returned and nothing is changed.
+func (p *Loader) Load(c *Config) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		prev  = make(map[string]*loadedEntry, len(p.loaded))
+		next  []*loadedEntry
+		count = make(map[string]int)
+
+		// undo reverts changes on errors, while replaced registrations are closed on success.
+		undo     []func()
+		replaced []x.CloseFunc
+	)
+	for _, v := range p.loaded {
+		prev[v.id] = v
+	}
+
+	update := func(kind string, old *loadedEntry, e Entry) (*registration, error) {
+		r, err := p.router(kind)
+		if err != nil {
+			return nil, err
+		}
+		if old == nil {
+			reg, err := add(r, e)
+			if err != nil {
+				return nil, err
+			}
+			undo = append(undo, reg.Close)
+			return reg, nil
+		}
+
+		reg := *old.registration
+		if old.middlewareKey != e.middlewareKey() {
+			reg.middleware = nil
+			if len(e.Middleware) > 0 {
+				if reg.middleware, err = r.use(e); err != nil {
+					return nil, err
+				}
+				undo = append(undo, reg.middleware)
+			}
+			if old.middleware != nil {
+				replaced = append(replaced, old.middleware)
+			}
+		}
+
+		switch {
+		case e.Handler == nil:
+			if old.handler != nil {
+				replaced = append(replaced, old.handler)
+			}
+			reg.handler, reg.swap = nil, nil
+		case old.handler == nil:
+			if reg.handler, reg.swap, err = r.handle(e); err != nil {
+				return nil, err
+			}
+			undo = append(undo, reg.handler)
+		case old.handlerKey != e.handlerKey():
+			f, err := old.swap(*e.Handler, e.meta())
+			if err != nil {
+				return nil, err
+			}
+			undo = append(undo, f)
+		}
+		return &reg, nil
+	}
+
+	load := func(kind string, entries []Entry) error {
+		for i, e := range entries {
+			id := kind + " " + e.Route
+			count[id]++
+			id = fmt.Sprintf("%s #%d", id, count[id])
+
+			old := prev[id]
+			delete(prev, id)
+			reg, err := update(kind, old, e)
+			if err != nil {
+				return fmt.Errorf("%s[%d] %q: %w", kind, i, e.Route, err)
+			}
+			next = append(next, &loadedEntry{
+				registration:  reg,
+				id:            id,
+				middlewareKey: e.middlewareKey(),
+				handlerKey:    e.handlerKey(),
+			})
+		}
+		return nil
+	}
+
+	err := load("http", c.HTTP)
+	if err == nil {
+		err = load("dns", c.DNS)
+	}
+	if err != nil {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		return err
+	}
+
+	for _, closer := range replaced {
+		closer()
+	}
+	for _, v := range p.loaded {
+		if _, ok := prev[v.id]; ok {
+			v.Close()
+		}
+	}
+	p.loaded = next
+	return nil
+}
+
+// LoadFile applies a config file, see `Load`.
+func (p *Loader) LoadFile(name string) error {
+	c, err := ParseFile(name)
+	if err != nil {
+		return err
+	}
+	return p.Load(c)
+}
+
+// Close closes all loaded entries in order.
+func (p *Loader) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, v := range p.loaded {
+		v.Close()
+	}
+	p.loaded = nil
+}
+
+// router returns the router of the kind of entries.
+func (p *Loader) router(kind string) (entryRouter, error) {
+	switch kind {
+	case "http":
+		if p.HTTP == nil {
+			return nil, errors.New("no HTTP router")
+		}
+		return httpEntries{p.Registry, p.HTTP}, nil
+	default:
+		if p.DNS == nil {
+			return nil, errors.New("no DNS router")
+		}
+		return dnsEntries{p.Registry, p.DNS}, nil
+	}
+}
@@ -0,0 +1,299 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	dnsMux "github.com/vegertar/mux/dns"
+	httpMux "github.com/vegertar/mux/http"
+	"github.com/vegertar/mux/x"
+)
+
+type (
+	// HTTPHandlerFactory creates an HTTP handler with arguments.
+	HTTPHandlerFactory func(args map[string]string) (http.Handler, error)
+	// HTTPMiddlewareFactory creates an HTTP middleware with arguments.
+	HTTPMiddlewareFactory func(args map[string]string) (httpMux.Middleware, error)
+	// DNSHandlerFactory creates a DNS handler with arguments.
+	DNSHandlerFactory func(args map[string]string) (dnsMux.Handler, error)
+	// DNSMiddlewareFactory creates a DNS middleware with arguments.
+	DNSMiddlewareFactory func(args map[string]string) (dnsMux.Middleware, error)
+
+	// Registry holds named factories of handlers and middleware.
+	Registry struct {
+		mu             sync.RWMutex
+		httpHandlers   map[string]HTTPHandlerFactory
+		httpMiddleware map[string]HTTPMiddlewareFactory
+		dnsHandlers    map[string]DNSHandlerFactory
+		dnsMiddleware  map[string]DNSMiddlewareFactory
+	}
+)
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		httpHandlers:   make(map[string]HTTPHandlerFactory),
+		httpMiddleware: make(map[string]HTTPMiddlewareFactory),
+		dnsHandlers:    make(map[string]DNSHandlerFactory),
+		dnsMiddleware:  make(map[string]DNSMiddlewareFactory),
+	}
+}
+
+// HTTPHandler registers an HTTP handler factory, an existed one with the same name is replaced.
+func (p *Registry) HTTPHandler(name string, f HTTPHandlerFactory) {
+	p.mu.Lock()
+	p.httpHandlers[name] = f
+	p.mu.Unlock()
+}
+
+// HTTPMiddleware registers an HTTP middleware factory, an existed one with the same name is replaced.
+func (p *Registry) HTTPMiddleware(name string, f HTTPMiddlewareFactory) {
+	p.mu.Lock()
+	p.httpMiddleware[name] = f
+	p.mu.Unlock()
+}
+
+// DNSHandler registers a DNS handler factory, an existed one with the same name is replaced.
+func (p *Registry) DNSHandler(name string, f DNSHandlerFactory) {
+	p.mu.Lock()
+	p.dnsHandlers[name] = f
+	p.mu.Unlock()
+}
+
+// DNSMiddleware registers a DNS middleware factory, an existed one with the same name is replaced.
+func (p *Registry) DNSMiddleware(name string, f DNSMiddlewareFactory) {
+	p.mu.Lock()
+	p.dnsMiddleware[name] = f
+	p.mu.Unlock()
+}
+
+func (p *Registry) newHTTPHandler(c Component) (http.Handler, error) {
+	p.mu.RLock()
+	f, ok := p.httpHandlers[c.Name]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown HTTP handler %q", c.Name)
+	}
+	return f(c.Args)
+}
+
+func (p *Registry) newHTTPMiddleware(c Component) (httpMux.Middleware, error) {
+	p.mu.RLock()
+	f, ok := p.httpMiddleware[c.Name]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown HTTP middleware %q", c.Name)
+	}
+	return f(c.Args)
+}
+
+func (p *Registry) newDNSHandler(c Component) (dnsMux.Handler, error) {
+	p.mu.RLock()
+	f, ok := p.dnsHandlers[c.Name]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown DNS handler %q", c.Name)
+	}
+	return f(c.Args)
+}
+
+func (p *Registry) newDNSMiddleware(c Component) (dnsMux.Middleware, error) {
+	p.mu.RLock()
+	f, ok := p.dnsMiddleware[c.Name]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown DNS middleware %q", c.Name)
+	}
+	return f(c.Args)
+}
+
+// AddHTTP registers an entry into an HTTP router, the returned function closes all of its registrations.
+func (p *Registry) AddHTTP(router *httpMux.Router, e Entry) (x.CloseFunc, error) {
+	reg, err := add(httpEntries{p, router}, e)
+	if err != nil {
+		return nil, err
+	}
+	return reg.Close, nil
+}
+
+// AddDNS registers an entry into a DNS router, the returned function closes all of its registrations.
+func (p *Registry) AddDNS(router *dnsMux.Router, e Entry) (x.CloseFunc, error) {
+	reg, err := add(dnsEntries{p, router}, e)
+	if err != nil {
+		return nil, err
+	}
+	return reg.Close, nil
+}
+
+type (
+	// entryRouter registers middleware and handlers of entries into a router.
+	entryRouter interface {
+		use(e Entry) (x.CloseFunc, error)
+		handle(e Entry) (x.CloseFunc, swapFunc, error)
+	}
+
+	// swapFunc replaces a registered handler along with metadata in place,
+	// the returned function swaps back to the previous one.
+	swapFunc func(c Component, meta *x.Meta) (undo func(), err error)
+
+	// registration is registrations of an entry, each field is nil if the entry has no such part.
+	registration struct {
+		middleware x.CloseFunc
+		handler    x.CloseFunc
+		swap       swapFunc
+	}
+
+	httpEntries struct {
+		*Registry
+		router *httpMux.Router
+	}
+
+	dnsEntries struct {
+		*Registry
+		router *dnsMux.Router
+	}
+)
+
+// add registers middleware and the handler of an entry, nothing is registered on errors.
+func add(r entryRouter, e Entry) (_ *registration, err error) {
+	reg := new(registration)
+	defer func() {
+		if err != nil {
+			reg.Close()
+		}
+	}()
+
+	if len(e.Middleware) > 0 {
+		if reg.middleware, err = r.use(e); err != nil {
+			return nil, err
+		}
+	}
+	if e.Handler != nil {
+		if reg.handler, reg.swap, err = r.handle(e); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+// Close closes the middleware and the handler.
+func (r *registration) Close() {
+	if r.middleware != nil {
+		r.middleware()
+	}
+	if r.handler != nil {
+		r.handler()
+	}
+}
+
+// newSwapFunc creates a swapFunc of the registration of the handler h.
+func newSwapFunc[H any](
+	reg interface{ SwapMeta(*x.Meta, H) error },
+	h H,
+	meta *x.Meta,
+	f func(Component) (H, error),
+) swapFunc {
+	return func(c Component, m *x.Meta) (func(), error) {
+		v, err := f(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := reg.SwapMeta(m, v); err != nil {
+			return nil, err
+		}
+
+		prev, prevMeta := h, meta
+		h, meta = v, m
+		return func() {
+			h, meta = prev, prevMeta
+			reg.SwapMeta(meta, h)
+		}, nil
+	}
+}
+
+func (p httpEntries) use(e Entry) (x.CloseFunc, error) {
+	route, err := httpMux.ParseRoute(e.Route)
+	if err != nil {
+		return nil, err
+	}
+
+	var m []httpMux.Middleware
+	for _, c := range e.Middleware {
+		v, err := p.newHTTPMiddleware(c)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, v)
+	}
+	if meta := e.meta(); meta != nil && e.Handler == nil {
+		return p.router.UseMeta(route, *meta, m...)
+	}
+	return p.router.Use(route, m...)
+}
+
+func (p httpEntries) handle(e Entry) (x.CloseFunc, swapFunc, error) {
+	route, err := httpMux.ParseRoute(e.Route)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h, err := p.newHTTPHandler(*e.Handler)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var opts []httpMux.HandleOption
+	meta := e.meta()
+	if meta != nil {
+		opts = append(opts, httpMux.WithMeta(*meta))
+	}
+	reg, err := p.router.Register(route, h, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reg.Close, newSwapFunc[http.Handler](reg, h, meta, p.newHTTPHandler), nil
+}
+
+func (p dnsEntries) use(e Entry) (x.CloseFunc, error) {
+	route, err := dnsMux.ParseRoute(e.Route)
+	if err != nil {
+		return nil, err
+	}
+
+	var m []dnsMux.Middleware
+	for _, c := range e.Middleware {
+		v, err := p.newDNSMiddleware(c)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, v)
+	}
+	if meta := e.meta(); meta != nil && e.Handler == nil {
+		return p.router.UseMeta(route, *meta, m...)
+	}
+	return p.router.Use(route, m...)
+}
+
+func (p dnsEntries) handle(e Entry) (x.CloseFunc, swapFunc, error) {
+	route, err := dnsMux.ParseRoute(e.Route)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h, err := p.newDNSHandler(*e.Handler)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var opts []dnsMux.HandleOption
+	meta := e.meta()
+	if meta != nil {
+		opts = append(opts, dnsMux.WithMeta(*meta))
+	}
+	reg, err := p.router.Register(route, h, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reg.Close, newSwapFunc[dnsMux.Handler](reg, h, meta, p.newDNSHandler), nil
+}
@@ -0,0 +1,53 @@
+package dns
+
+import (
+	"errors"
+
+	"github.com/miekg/dns"
+	"github.com/vegertar/mux/x"
+)
+
+// Explanation is the decision path of answering a question, see `Router.Explain`.
+type Explanation struct {
+	// Steps are nodes visited in order, in which levels are name, type and class. Steps of looking up
+	// CNAME, NS or SOA records of a name without the questioned data are marked by `x.Step.Fallback`,
+	// and a step marked by `NOERROR` tells that nothing is found for an existed name.
+	Steps []x.Step
+	// Handlers are routes whose handlers answer the question, which might be CNAME, NS or SOA routes.
+	Handlers []Route
+	// Middleware are routes whose middleware wrap the handlers, from the outermost to the innermost.
+	Middleware []Route
+}
+
+// Explain tells how the first question of a message is matched, i.e. every candidate edge tried
+// at each level, and which routes contribute handlers and middleware.
+func (p *Router) Explain(msg *dns.Msg) (*Explanation, error) {
+	if len(msg.Question) == 0 {
+		return nil, errors.New("no question")
+	}
+
+	route, err := newRoute(requestRoute(msg))
+	if err != nil {
+		return nil, err
+	}
+
+	v := p.Router.Explain(route)
+	e := &Explanation{Steps: v.Steps}
+	for _, label := range v.Labels {
+		// the synthesized NOERROR label has no node
+		if len(label.Handler) > 0 && label.Node != nil {
+			e.Handlers = append(e.Handlers, labelRoute(label))
+		}
+		if len(label.Middleware) > 0 {
+			e.Middleware = append(e.Middleware, labelRoute(label))
+		}
+	}
+	return e, nil
+}
+
+// labelRoute returns the route of a label along with its metadata.
+func labelRoute(label *x.Label[Handler, Middleware]) Route {
+	r := routeFromKeys(label.Route())
+	r.Meta = label.Meta
+	return r
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/miekg/dns"
 	"github.com/vegertar/mux/x"
+	"github.com/vegertar/mux/x/radix"
 )
 
 // A ResponseWriter interface is used by a DNS handler to construct an DNS response.
@@ -142,31 +143,71 @@ func (f HandlerFunc) ServeDNS(w ResponseWriter, r *Request) {
 type MultiHandler []Handler
 
 // ServeDNS implements `Handler` interface.
+// If the router is configured with a balancer, only one handler selected by it is called.
 func (m MultiHandler) ServeDNS(w ResponseWriter, r *Request) {
-	for _, h := range m {
-		h.ServeDNS(w, r)
+	serveHandlers(w, r, m, nil)
+}
+
+// pool is handlers of labels along with their precomputed weights.
+type pool struct {
+	handlers []Handler
+	weights  []*x.Weight
+}
+
+// ServeDNS implements `Handler` interface.
+func (p *pool) ServeDNS(w ResponseWriter, r *Request) {
+	serveHandlers(w, r, p.handlers, p.weights)
+}
+
+// newPool returns a handler like `MultiHandler`, weights are ignored unless aligned with handlers.
+func newPool(handlers []Handler, weights []*x.Weight) *pool {
+	if len(weights) != len(handlers) {
+		weights = nil
 	}
+	return &pool{handlers, weights}
+}
+
+// wrappedPool is a pool wrapped by middleware, handlers of the pool are kept for pinning.
+type wrappedPool struct {
+	Handler
+	pool *pool
 }
 
-func newMultiHandler(handler ...interface{}) MultiHandler {
-	m := make([]Handler, 0, len(handler))
-	for _, v := range handler {
-		if v != nil {
-			m = append(m, v.(Handler))
+// wrapPool returns a pool of handlers wrapped by the middleware.
+func wrapPool(m Middleware, handlers []Handler, weights []*x.Weight) Handler {
+	p := newPool(handlers, weights)
+	return &wrappedPool{m.GenerateHandler(p), p}
+}
+
+// serveHandlers calls all handlers, or the one selected by the balancer if any.
+func serveHandlers(w ResponseWriter, r *Request, handlers []Handler, weights []*x.Weight) {
+	if b, ok := r.Context().Value(balanceKey).(balance); ok && len(handlers) > 0 {
+		h, ok := x.Select(b.balancer, handlers, weights, b.key)
+		if !ok {
+			h = FailureErrorHandler
+		}
+		// releases references of handlers not selected
+		if pins := pinsFrom(r); pins != nil {
+			pins.Keep(inflights(h)...)
 		}
+		h.ServeDNS(w, r)
+		return
 	}
-	if len(m) == 0 {
-		return nil
+
+	for _, h := range handlers {
+		if h != nil {
+			h.ServeDNS(w, r)
+		}
 	}
-	return MultiHandler(m)
 }
 
-func newHandlerFromLabels(route x.Route, labels []*x.Label) Handler {
+func newHandlerFromLabels(route x.Route, labels []*x.Label[Handler, Middleware]) Handler {
 	var (
 		h Handler = RefusedErrorHandler
 
-		handlers   []interface{}
-		middleware []interface{}
+		handlers   []Handler
+		weights    []*x.Weight
+		middleware []Middleware
 	)
 
 	if len(labels) > 0 {
@@ -175,16 +216,17 @@ func newHandlerFromLabels(route x.Route, labels []*x.Label) Handler {
 
 		for _, label := range labels {
 			handlers = append(handlers, label.Handler...)
+			weights = append(weights, label.Weights...)
 			middleware = append(middleware, label.Middleware...)
 		}
 	}
 	if len(handlers) > 0 {
-		h = newMultiHandler(handlers...)
+		h = newPool(handlers, weights)
 	}
 
 	for i := range middleware {
 		if m := middleware[len(middleware)-1-i]; m != nil {
-			h = m.(Middleware).GenerateHandler(h)
+			h = m.GenerateHandler(h)
 		}
 	}
 
@@ -194,7 +236,7 @@ func newHandlerFromLabels(route x.Route, labels []*x.Label) Handler {
 	return h
 }
 
-func getVars(route x.Route, label *x.Label) Middleware {
+func getVars(route x.Route, label *x.Label[Handler, Middleware]) Middleware {
 	return MiddlewareFunc(func(h Handler) Handler {
 		var varsValue VarsValue
 
@@ -206,6 +248,14 @@ func getVars(route x.Route, label *x.Label) Middleware {
 		for _, k := range nameKey.Capture(route[0]) {
 			varsValue.Name = append(varsValue.Name, k.StringWith("."))
 		}
+		for i, k := range nameKey.Align(route[0]) {
+			if p, ok := nameKey[i].(*radix.ParamLabel); ok {
+				if varsValue.Params == nil {
+					varsValue.Params = make(map[string]string)
+				}
+				varsValue.Params[p.Name()] = k.StringWith(".")
+			}
+		}
 
 		return HandlerFunc(func(w ResponseWriter, r *Request) {
 			h.ServeDNS(w, r.WithContext(context.WithValue(r.Context(), varsKey, varsValue)))
@@ -224,13 +274,20 @@ func Vars(r *Request) VarsValue {
 type (
 	contextKey int
 
-	// VarsValue is the value of positional patterns.
+	// VarsValue is the value of positional and named patterns.
 	VarsValue struct {
 		// Name is the value of host patterns in which [0] is the entire pattern, [1] is the first field, etc.
 		Name []string
+		// Params is the value of named fields, e.g. `{zone:hex}`.
+		Params map[string]string
 	}
 )
 
+// Get returns the value of a named field.
+func (v VarsValue) Get(name string) string {
+	return v.Params[name]
+}
+
 const (
 	varsKey contextKey = iota
 
@@ -239,6 +296,9 @@ const (
 	// routine the handler. The associated value will be of
 	// type *Router.
 	RouterContextKey
+
+	balanceKey
+	pinsKey
 )
 
 // ErrorHandler responses a given code to client.
@@ -7,27 +7,33 @@ import (
 
 // Node derives the `x.RadixNode` with specialized DNS matching.
 type Node struct {
-	*x.RadixNode
+	*x.RadixNode[Handler, Middleware]
 }
 
 // Match implements the `x.Node` interface.
-func (p *Node) Match(route x.Route) (leaves []*x.Label) {
+func (p *Node) Match(route x.Route) []*x.Label[Handler, Middleware] {
+	return p.Explain(route, nil)
+}
+
+// Explain implements the `x.Node` interface, in which the CNAME, NS and SOA lookups of a name
+// without the questioned data are recorded as fallbacks.
+func (p *Node) Explain(route x.Route, trace *x.Trace) (leaves []*x.Label[Handler, Middleware]) {
 	if len(route) > 2 {
 		// first matches qname only
-		nameLeaves := p.RadixNode.Match(route[:1])
+		nameLeaves := p.RadixNode.Explain(route[:1], trace)
 		typeAndClass := route[1:]
 		qtype := route[1][0].String()
 
 		for index, nameLeaf := range nameLeaves {
 			// then matches qtype and qclass
 			down := nameLeaf.Down
-			v := down.Match(typeAndClass)
+			v := down.Explain(typeAndClass, trace)
 			noData := true
 
 			for i, leaf := range v {
 				// remains middleware only for non-first matches
 				if index > 0 {
-					leaf.Handler = nil
+					leaf.Handler, leaf.Weights = nil, nil
 				}
 				if len(leaf.Handler) > 0 {
 					noData = false
@@ -45,8 +51,8 @@ func (p *Node) Match(route x.Route) (leaves []*x.Label) {
 						middleware = p.cnameMiddleware(qtype)
 					}
 					if middleware != nil {
-						h := middleware.GenerateHandler(newMultiHandler(leaf.Handler...))
-						leaf.Handler = []interface{}{h}
+						h := wrapPool(middleware, leaf.Handler, leaf.Weights)
+						leaf.Handler, leaf.Weights = []Handler{h}, nil
 						v[i] = leaf
 					}
 				}
@@ -65,27 +71,34 @@ func (p *Node) Match(route x.Route) (leaves []*x.Label) {
 					if err != nil {
 						panic(err)
 					}
-					labels := down.Match(x.Route{cnameKey, route[2]})
+					var labels []*x.Label[Handler, Middleware]
+					trace.Fallback("CNAME", func() {
+						labels = down.Explain(x.Route{cnameKey, route[2]}, trace)
+					})
 					for i, leaf := range labels {
 						if len(leaf.Handler) > 0 {
 							noData = false
-							h := p.cnameMiddleware(qtype).GenerateHandler(newMultiHandler(leaf.Handler...))
-							leaf.Handler = []interface{}{h}
+							h := wrapPool(p.cnameMiddleware(qtype), leaf.Handler, leaf.Weights)
+							leaf.Handler, leaf.Weights = []Handler{h}, nil
 							labels[i] = leaf
 						}
 					}
-					if noData {
+					if !noData {
+						v = append(v, labels...)
+					} else {
 						// if no data then checks NS
 						nsKey, err := x.NewStringSliceKey(nsType)
 						if err != nil {
 							panic(err)
 						}
-						labels = down.Match(x.Route{nsKey, route[2]})
+						trace.Fallback("NS", func() {
+							labels = down.Explain(x.Route{nsKey, route[2]}, trace)
+						})
 						for i, leaf := range labels {
 							if len(leaf.Handler) > 0 {
 								noData = false
-								h := p.glueMiddleware(true).GenerateHandler(newMultiHandler(leaf.Handler...))
-								leaf.Handler = []interface{}{h}
+								h := wrapPool(p.glueMiddleware(true), leaf.Handler, leaf.Weights)
+								leaf.Handler, leaf.Weights = []Handler{h}, nil
 								labels[i] = leaf
 							}
 						}
@@ -101,12 +114,15 @@ func (p *Node) Match(route x.Route) (leaves []*x.Label) {
 					if err != nil {
 						panic(err)
 					}
-					labels := down.Match(x.Route{soaKey, route[2]})
+					var labels []*x.Label[Handler, Middleware]
+					trace.Fallback("SOA", func() {
+						labels = down.Explain(x.Route{soaKey, route[2]}, trace)
+					})
 					for i, leaf := range labels {
 						if len(leaf.Handler) > 0 {
 							noData = false
-							h := p.soaMiddleware(false).GenerateHandler(newMultiHandler(leaf.Handler...))
-							leaf.Handler = []interface{}{h}
+							h := wrapPool(p.soaMiddleware(false), leaf.Handler, leaf.Weights)
+							leaf.Handler, leaf.Weights = []Handler{h}, nil
 							labels[i] = leaf
 						}
 					}
@@ -116,9 +132,16 @@ func (p *Node) Match(route x.Route) (leaves []*x.Label) {
 				}
 
 				if noData {
-					noError := new(x.Label)
+					if trace != nil {
+						trace.Steps = append(trace.Steps, x.Step{
+							Level:    1,
+							Path:     x.Route{nameLeaf.Key},
+							Fallback: "NOERROR",
+						})
+					}
+					noError := new(x.Label[Handler, Middleware])
 					noError.Key = nameLeaf.Key
-					noError.Handler = []interface{}{NoErrorHandler}
+					noError.Handler = []Handler{NoErrorHandler}
 					v = append(v, noError)
 				}
 			}
@@ -129,7 +152,7 @@ func (p *Node) Match(route x.Route) (leaves []*x.Label) {
 		return
 	}
 
-	return p.RadixNode.Match(route)
+	return p.RadixNode.Explain(route, trace)
 }
 
 func (p *Node) cnameMiddleware(qtype string) Middleware {
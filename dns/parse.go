@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/miekg/dns"
+	"github.com/vegertar/mux/x"
+	"github.com/vegertar/mux/x/radix"
+)
+
+// ParseRoute parses a route from the representation returned by `Route.String`, i.e. `name [TYPE [CLASS]]`,
+// e.g. `*.example.com AAAA IN`. An unspecified name is written as `**`, and type and class default to `A`
+// and `IN`. The result of parsing `r.String()` is equivalent to r, and its string representation is the
+// same as `r.String()`.
+func ParseRoute(s string) (Route, error) {
+	var r Route
+
+	fields := splitFields(s)
+	if len(fields) == 0 {
+		return r, &x.ParseError{Input: s, Offset: len(s), Err: errors.New("missing name")}
+	}
+	if len(fields) > 3 {
+		return r, fields[3].error(s, errors.New("unexpected field"))
+	}
+
+	name := fields[0]
+	if name.s != wildcards {
+		r.Name = name.s
+	}
+	if _, err := x.NewGlobSliceKey(splitName(name.s, false)); err != nil {
+		return r, name.error(s, err)
+	}
+
+	if len(fields) > 1 {
+		r.Type = strings.ToUpper(fields[1].s)
+		if err := checkField(r.Type, "type", dns.StringToType); err != nil {
+			return r, fields[1].error(s, err)
+		}
+	}
+	if len(fields) > 2 {
+		r.Class = strings.ToUpper(fields[2].s)
+		if err := checkField(r.Class, "class", dns.StringToClass); err != nil {
+			return r, fields[2].error(s, err)
+		}
+	}
+
+	return r, nil
+}
+
+// part is a field with the offset in the input.
+type part struct {
+	s      string
+	offset int
+}
+
+func (p part) error(input string, err error) error {
+	return &x.ParseError{Input: input, Offset: p.offset, Part: p.s, Err: err}
+}
+
+// splitFields splits s by spaces.
+func splitFields(s string) []part {
+	var (
+		out   []part
+		start = -1
+	)
+	for i, c := range s + " " {
+		switch {
+		case !unicode.IsSpace(c):
+			if start < 0 {
+				start = i
+			}
+		case start >= 0:
+			out = append(out, part{s[start:i], start})
+			start = -1
+		}
+	}
+	return out
+}
+
+// checkField checks a type or class is either known or a pattern.
+func checkField[T any](s, what string, known map[string]T) error {
+	if _, ok := known[s]; ok {
+		return nil
+	}
+
+	label, err := x.NewLabel(s)
+	if err != nil {
+		return err
+	}
+	if label.Literal() && !radix.IsPattern(s) {
+		return fmt.Errorf("unknown %s", what)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package dns
+
+import (
+	"net"
+
+	"github.com/vegertar/mux/x"
+)
+
+// HandleOption configures a handler added by `Router.Handle`.
+type HandleOption func(*handleOptions)
+
+type handleOptions struct {
+	weight *x.Weight
+	meta   *x.Meta
+}
+
+// WithMeta sets the metadata of the handler, which is returned by `Router.Routes`.
+func WithMeta(meta x.Meta) HandleOption {
+	return func(o *handleOptions) {
+		o.meta = &meta
+	}
+}
+
+// WithWeight sets the weight of the handler in the pool of the route, which is used by `Router.Balancer`.
+func WithWeight(w *x.Weight) HandleOption {
+	return func(o *handleOptions) {
+		o.weight = w
+	}
+}
+
+// optionHandler is a handler added with options.
+type optionHandler struct {
+	Handler
+	handleOptions
+}
+
+// wrap returns a handler carrying the options if any.
+func (o handleOptions) wrap(h Handler) Handler {
+	if o.weight != nil {
+		h = &optionHandler{Handler: h, handleOptions: o}
+	}
+	return h
+}
+
+// Weight implements the `x.Weighted` interface.
+func (h *optionHandler) Weight() *x.Weight {
+	return h.weight
+}
+
+// Unwrap implements the `x.Unwrapper` interface.
+func (h *optionHandler) Unwrap() Handler {
+	return h.Handler
+}
+
+// balance is the balancer and the key of a request.
+type balance struct {
+	balancer x.Balancer
+	key      string
+}
+
+// remoteHost returns the host of the remote address, or the question name if unavailable.
+func remoteHost(w ResponseWriter, r *Request) string {
+	if rw, ok := w.(*responseWriter); ok && rw.ResponseWriter != nil {
+		if addr := rw.RemoteAddr(); addr != nil {
+			if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+				return host
+			}
+			return addr.String()
+		}
+	}
+	if len(r.Question) > 0 {
+		return r.Question[0].Name
+	}
+	return ""
+}
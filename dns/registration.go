@@ -0,0 +1,113 @@
+package dns
+
+import (
+	"context"
+
+	"github.com/vegertar/mux/x"
+)
+
+// Registration is a handle of a handler added by `Router.Register`.
+type Registration struct {
+	*x.Registration[Handler]
+
+	options  handleOptions
+	inflight *x.Inflight
+}
+
+// Swap replaces the handler atomically, options of the registration are kept.
+func (r *Registration) Swap(h Handler) error {
+	return r.Registration.Swap(r.wrap(h))
+}
+
+// SwapMeta is like `Swap` along with metadata, a nil meta removes the metadata.
+func (r *Registration) SwapMeta(meta *x.Meta, h Handler) error {
+	return r.Registration.SwapMeta(meta, r.wrap(h))
+}
+
+// CloseContext unloads the handler like `Close`, then waits until in-flight requests dispatched to
+// the handler complete. A request is counted once the handler is selected by `Router.ServeDNS`,
+// i.e. before middleware runs. It returns the error of the context if it's done before that.
+func (r *Registration) CloseContext(ctx context.Context) error {
+	r.Close()
+	return r.inflight.Wait(ctx)
+}
+
+// Inflight returns the number of in-flight requests dispatched to the handler.
+func (r *Registration) Inflight() int {
+	return r.inflight.Len()
+}
+
+func (r *Registration) wrap(h Handler) Handler {
+	return r.options.wrap(&trackedHandler{Handler: h, inflight: r.inflight})
+}
+
+// trackedHandler counts in-flight requests which are not pinned by the router, e.g. served by the
+// handler returned from `Router.Match`.
+type trackedHandler struct {
+	Handler
+	inflight *x.Inflight
+}
+
+// Unwrap implements the `x.Unwrapper` interface.
+func (h *trackedHandler) Unwrap() Handler {
+	return h.Handler
+}
+
+// ServeDNS implements `Handler` interface.
+func (h *trackedHandler) ServeDNS(w ResponseWriter, r *Request) {
+	if !pinsFrom(r).Has(h.inflight) {
+		h.inflight.Enter()
+		defer h.inflight.Leave()
+	}
+	h.Handler.ServeDNS(w, r)
+}
+
+// walkInflight calls f with in-flight counters of handlers added by `Router.Register`, including
+// the ones in pools, until f returns false.
+func walkInflight(h Handler, f func(*x.Inflight) bool) bool {
+	switch v := h.(type) {
+	case *wrappedPool:
+		return walkInflight(v.pool, f)
+	case *pool:
+		for _, h := range v.handlers {
+			if !walkInflight(h, f) {
+				return false
+			}
+		}
+	case *optionHandler:
+		return walkInflight(v.Handler, f)
+	case *trackedHandler:
+		return f(v.inflight)
+	}
+	return true
+}
+
+// inflights returns in-flight counters of a handler.
+func inflights(h Handler) []*x.Inflight {
+	var v []*x.Inflight
+	walkInflight(h, func(in *x.Inflight) bool {
+		v = append(v, in)
+		return true
+	})
+	return v
+}
+
+// pin takes in-flight references of handlers of labels, it returns false if any is draining.
+func pin(labels []*x.Label[Handler, Middleware]) (*x.Pins, bool) {
+	pins := new(x.Pins)
+	for _, label := range labels {
+		for _, h := range label.Handler {
+			if !walkInflight(h, pins.Add) {
+				pins.Release()
+				return nil, false
+			}
+		}
+	}
+	return pins, true
+}
+
+// pinsFrom returns the in-flight references taken for the request if any.
+func pinsFrom(r *Request) *x.Pins {
+	pins, _ := r.Context().Value(pinsKey).(*x.Pins)
+	return pins
+}
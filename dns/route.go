@@ -25,13 +25,17 @@ type Route struct {
 	Type       string
 	Class      string
 	UseLiteral bool
+	// Meta is metadata of handlers and middleware on the route, which is filled by `Router.Routes`
+	// and ignored when adding routes.
+	Meta []x.Meta
 }
 
-// String returns the string representation.
+// String returns the string representation, i.e. `name TYPE CLASS`, in which an unspecified
+// name is written as `**`, and unspecified type and class are written as the defaults `A` and `IN`.
 func (r Route) String() string {
-	name, typ, class := wildcards, wildcards, wildcards
+	name, typ, class := wildcards, aType[0], inClass[0]
 	if len(r.Name) > 0 {
-		name = strings.ToLower(r.Name)
+		name = strings.Join(x.MapPattern(radix.SplitPattern(r.Name, "."), strings.ToLower), ".")
 	}
 	if len(r.Type) > 0 {
 		typ = strings.ToUpper(r.Type)
@@ -54,12 +58,10 @@ func newRoute(r Route) (x.Route, error) {
 		err error
 	)
 
-	if len(r.Name) > 0 {
-		r.Name = strings.ToLower(r.Name)
-	} else {
+	if len(r.Name) == 0 {
 		r.Name = wildcards
 	}
-	name := dns.SplitDomainName(r.Name)
+	name := splitName(r.Name, r.UseLiteral)
 	reverse(name)
 
 	key, err = f(name)
@@ -100,6 +102,20 @@ func RR(r dns.RR) Route {
 	}
 }
 
+// splitName splits a domain name into lower case labels,
+// regular expressions in a pattern are kept as is.
+func splitName(s string, literal bool) []string {
+	if literal {
+		return dns.SplitDomainName(strings.ToLower(s))
+	}
+
+	s = strings.TrimSuffix(s, ".")
+	if s == "" {
+		return nil
+	}
+	return x.MapPattern(radix.SplitPattern(s, "."), strings.ToLower)
+}
+
 func reverse(s []string) {
 	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
 		s[i], s[j] = s[j], s[i]
@@ -11,14 +11,23 @@ import (
 
 // Router is a wrapper of DNS mux.
 type Router struct {
-	*x.Router
+	*x.Router[Handler, Middleware]
+
+	// Balancer enables to treat multiple handlers of a route as a pool, only one handler selected by
+	// the balancer is called for each request, weights of handlers are set by `WithWeight`.
+	// If no handler is available, `dns.RcodeServerFailure` is replied.
+	Balancer x.Balancer
+
+	// BalanceKey returns the key of a request for the balancer, e.g. for consistent hashing.
+	// If nil, the host of the remote address is used, or the question name if unavailable.
+	BalanceKey func(ResponseWriter, *Request) string
 }
 
 // NewRouter creates a DNS router.
 func NewRouter() *Router {
 	return &Router{
-		Router: &x.Router{
-			Breed: func(up *x.Label) x.Node {
+		Router: &x.Router[Handler, Middleware]{
+			Breed: func(up *x.Label[Handler, Middleware]) x.Node[Handler, Middleware] {
 				return &Node{
 					RadixNode: x.NewRadixNode(up),
 				}
@@ -31,22 +40,40 @@ func NewRouter() *Router {
 func (p *Router) Routes() []Route {
 	var out []Route
 
-	for _, route := range p.Router.Routes() {
-		var r Route
-		names := route[0].Strings()
-		reverse(names)
-		r.Name = strings.Join(names, ".")
+	routes, labels := p.Router.RouteLabels()
+	for i, route := range routes {
+		r := routeFromKeys(route)
+		r.Meta = labels[i].Meta
+		out = append(out, r)
+	}
 
-		if len(route) > 1 {
-			r.Class = route[1][0].String()
-		}
-		if len(route) > 2 {
-			r.Type = route[2][0].String()
-		}
+	return out
+}
 
-		out = append(out, r)
+// routeFromKeys converts pattern keys of levels into a route.
+func routeFromKeys(route x.Route) Route {
+	var r Route
+	names := route[0].Strings()
+	reverse(names)
+	r.Name = strings.Join(names, ".")
+
+	if len(route) > 1 {
+		r.Type = route[1][0].String()
+	}
+	if len(route) > 2 {
+		r.Class = route[2][0].String()
 	}
+	return r
+}
 
+// RoutesByTag returns registered route sequences which have metadata with the given tag.
+func (p *Router) RoutesByTag(tag string) []Route {
+	var out []Route
+	for _, r := range p.Routes() {
+		if x.HasTag(r.Meta, tag) {
+			out = append(out, r)
+		}
+	}
 	return out
 }
 
@@ -66,12 +93,17 @@ func (p *Router) Use(c Route, m ...Middleware) (x.CloseFunc, error) {
 		return nil, err
 	}
 
-	m2 := make([]interface{}, 0, len(m))
-	for _, v := range m {
-		m2 = append(m2, v)
+	return p.Router.Use(r, m...)
+}
+
+// UseMeta associates a route with middleware along with metadata.
+func (p *Router) UseMeta(c Route, meta x.Meta, m ...Middleware) (x.CloseFunc, error) {
+	r, err := newRoute(c)
+	if err != nil {
+		return nil, err
 	}
 
-	return p.Router.Use(r, m2...)
+	return p.Router.UseMeta(r, &meta, m...)
 }
 
 // UseFunc associates a route with middleware functions.
@@ -84,38 +116,108 @@ func (p *Router) UseFunc(r Route, m ...MiddlewareFunc) (x.CloseFunc, error) {
 }
 
 // Handle associates a route with a `Handler`.
-func (p *Router) Handle(c Route, h Handler) (x.CloseFunc, error) {
+func (p *Router) Handle(c Route, h Handler, opts ...HandleOption) (x.CloseFunc, error) {
+	reg, err := p.Register(c, h, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return reg.Close, nil
+}
+
+// Register associates a route with a `Handler` like `Handle`, but returns a registration
+// by which the handler can be swapped without a routing gap.
+func (p *Router) Register(c Route, h Handler, opts ...HandleOption) (*Registration, error) {
 	r, err := newRoute(c)
 	if err != nil {
 		return nil, err
 	}
-	return p.Router.Handle(r, h)
+
+	var o handleOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	reg := &Registration{options: o, inflight: new(x.Inflight)}
+	reg.Registration, err = p.Router.RegisterMeta(r, o.meta, reg.wrap(h))
+	if err != nil {
+		return nil, err
+	}
+	return reg, nil
 }
 
 // HandleFunc associates a route with an `HandlerFunc`.
-func (p *Router) HandleFunc(r Route, h HandlerFunc) (x.CloseFunc, error) {
-	return p.Handle(r, h)
+func (p *Router) HandleFunc(r Route, h HandlerFunc, opts ...HandleOption) (x.CloseFunc, error) {
+	return p.Handle(r, h, opts...)
 }
 
 // ServeDNS implements `Handler` interface.
 func (p *Router) ServeDNS(w ResponseWriter, req *Request) {
-	var r Route
-	r.Class = dns.ClassToString[req.Question[0].Qclass]
-	r.Type = dns.TypeToString[req.Question[0].Qtype]
-	r.Name = req.Question[0].Name
-	r.UseLiteral = true
+	r := requestRoute(req.Msg)
 
-	var h Handler
+	var (
+		h    Handler
+		pins *x.Pins
+	)
 	if r.Class == "ANY" || r.Class == "" || r.Type == "ANY" || r.Type == "" {
 		h = FormatErrorHandler
+	} else if route, err := newRoute(r); err != nil {
+		h = FailureErrorHandler
 	} else {
-		h = p.Match(r)
+		labels := p.Router.Match(route)
+		// counts the request before middleware runs, and matches again if any handler is draining
+		for ok := false; !ok; {
+			if pins, ok = pin(labels); !ok {
+				labels = p.Router.Match(route)
+			}
+		}
+		defer pins.Release()
+		h = newHandlerFromLabels(route, labels)
 	}
 
 	ctx := context.WithValue(req.Context(), RouterContextKey, p)
+	ctx = context.WithValue(ctx, pinsKey, pins)
+	if p.Balancer != nil {
+		key := p.BalanceKey
+		if key == nil {
+			key = remoteHost
+		}
+		ctx = context.WithValue(ctx, balanceKey, balance{p.Balancer, key(w, req)})
+	}
 	h.ServeDNS(w, req.WithContext(ctx))
 }
 
+// Lookup returns the registered route which answers the question of a message, which might be
+// a CNAME, NS or SOA route if no data of the questioned type exists. The returned route is false
+// if no handler is matched.
+func (p *Router) Lookup(msg *dns.Msg) (Route, bool) {
+	if len(msg.Question) == 0 {
+		return Route{}, false
+	}
+
+	route, err := newRoute(requestRoute(msg))
+	if err != nil {
+		return Route{}, false
+	}
+
+	for _, label := range p.Router.Match(route) {
+		// skips labels without handlers and the synthesized NOERROR one
+		if len(label.Handler) > 0 && label.Node != nil {
+			return labelRoute(label), true
+		}
+	}
+	return Route{}, false
+}
+
+// requestRoute returns the literal route of the first question.
+func requestRoute(msg *dns.Msg) Route {
+	var r Route
+	r.Class = dns.ClassToString[msg.Question[0].Qclass]
+	r.Type = dns.TypeToString[msg.Question[0].Qtype]
+	r.Name = msg.Question[0].Name
+	r.UseLiteral = true
+	return r
+}
+
 // ServeFunc returns a `dns.HandlerFunc`.
 func (p *Router) ServeFunc(ctx context.Context) dns.HandlerFunc {
 	return func(w dns.ResponseWriter, r *dns.Msg) {
@@ -1,7 +1,9 @@
 package dns
 
 import (
+	"context"
 	"reflect"
+	"sort"
 	"strconv"
 	"testing"
 	"time"
@@ -84,6 +86,7 @@ func TestRouter_ServeDNS(t *testing.T) {
 		{Name: "v[2-3]"},
 		{Name: "v4.**.x"},
 		{Name: "v4.*.**.x"},
+		{Name: "{re:^shard[0-9]+$}.v5"},
 	}
 
 	router := NewRouter()
@@ -162,6 +165,16 @@ func TestRouter_ServeDNS(t *testing.T) {
 				"** A IN",
 			},
 		},
+		{
+			Route{Name: "shard1.v5"},
+			[]string{
+				"{re:^shard[0-9]+$}.v5 A IN",
+			},
+			[]string{
+				"{re:^shard[0-9]+$}.v5 A IN",
+				"** A IN",
+			},
+		},
 	}
 
 	for i, c := range cases {
@@ -190,6 +203,430 @@ func TestRouter_ServeDNS(t *testing.T) {
 	}
 }
 
+func TestVars(t *testing.T) {
+	router := NewRouter()
+	routes := []Route{
+		{Name: "{zone:hex}.example.com"},
+		{Name: "*.example.com"},
+	}
+	for _, route := range routes {
+		_, err := router.HandleFunc(route, func(w ResponseWriter, r *Request) {
+			vars := Vars(r)
+			a := new(dns.A)
+			a.Hdr.Name = vars.Name[0] + " " + vars.Get("zone")
+			w.Answer(a)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name string
+		y    string
+	}{
+		{"0a.example.com.", "com.example.{zone:hex} 0a"},
+		{"0x.example.com.", "com.example.* "},
+	}
+	for i, c := range cases {
+		request := &Request{
+			Msg: new(dns.Msg),
+		}
+		request.SetQuestion(c.name, dns.TypeA)
+		w := new(responseWriter)
+
+		router.ServeDNS(w, request)
+		if len(w.msg.Answer) != 1 {
+			t.Fatalf("bad case %d: expected 1 answer, got %v", i+1, w.msg.Answer)
+		}
+		if y := w.msg.Answer[0].Header().Name; y != c.y {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestRouter_Balancer(t *testing.T) {
+	blue, green := x.NewWeight("blue", 1), x.NewWeight("green", 1)
+
+	router := NewRouter()
+	for _, w := range []*x.Weight{blue, green} {
+		_, err := router.HandleFunc(Route{Name: "example.com"}, func(s string) HandlerFunc {
+			return func(w ResponseWriter, r *Request) {
+				a := new(dns.A)
+				a.Hdr.Name = s
+				w.Answer(a)
+			}
+		}(w.Name()), WithWeight(w))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serve := func() (y []string) {
+		request := &Request{
+			Msg: new(dns.Msg),
+		}
+		request.SetQuestion("example.com.", dns.TypeA)
+		w := new(responseWriter)
+
+		router.ServeDNS(w, request)
+		for _, rr := range w.msg.Answer {
+			y = append(y, rr.Header().Name)
+		}
+		return
+	}
+
+	if y := serve(); !reflect.DeepEqual(y, []string{"blue", "green"}) {
+		t.Errorf("expected all answers without balancer, got %v", y)
+	}
+
+	router.Balancer = new(x.WeightedRoundRobin)
+	var y []string
+	for i := 0; i < 4; i++ {
+		y = append(y, serve()...)
+	}
+	if expected := []string{"blue", "green", "blue", "green"}; !reflect.DeepEqual(y, expected) {
+		t.Errorf("expected %v, got %v", expected, y)
+	}
+
+	blue.Store(0)
+	if y := serve(); !reflect.DeepEqual(y, []string{"green"}) {
+		t.Errorf("expected green only after draining blue, got %v", y)
+	}
+}
+
+func TestRegistration_CloseContext(t *testing.T) {
+	router := NewRouter()
+	started, release := make(chan struct{}), make(chan struct{})
+	reg, err := router.Register(Route{}, HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(started)
+		<-release
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		request := &Request{
+			Msg: new(dns.Msg),
+		}
+		request.SetQuestion("example.com.", dns.TypeA)
+		router.ServeDNS(new(responseWriter), request)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := reg.CloseContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	close(release)
+	if err := reg.CloseContext(context.Background()); err != nil {
+		t.Errorf("expected drained, got %v", err)
+	}
+}
+
+func TestRegistration_CloseContextMiddleware(t *testing.T) {
+	router := NewRouter()
+	entered, release := make(chan struct{}), make(chan struct{})
+	_, err := router.UseFunc(Route{}, func(h Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			close(entered)
+			<-release
+			h.ServeDNS(w, r)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan struct{})
+	reg, err := router.Register(Route{}, HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(served)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		request := &Request{
+			Msg: new(dns.Msg),
+		}
+		request.SetQuestion("example.com.", dns.TypeA)
+		router.ServeDNS(new(responseWriter), request)
+	}()
+	<-entered
+	if n := reg.Inflight(); n != 1 {
+		t.Fatalf("expected 1 in-flight request, got %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := reg.CloseContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	close(release)
+	if err := reg.CloseContext(context.Background()); err != nil {
+		t.Errorf("expected drained, got %v", err)
+	}
+	select {
+	case <-served:
+	default:
+		t.Error("expected the handler is served before drained")
+	}
+}
+
+func TestRouter_Meta(t *testing.T) {
+	router := NewRouter()
+	handler := func(ResponseWriter, *Request) {}
+	_, err := router.HandleFunc(Route{Name: "example.com"}, handler, WithMeta(x.Meta{Name: "example", Tags: []string{"zone"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = router.HandleFunc(Route{Name: "example.org"}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := router.RoutesByTag("zone")
+	if len(routes) != 1 || routes[0].Name != "example.com" || routes[0].Meta[0].Name != "example" {
+		t.Errorf("bad routes by tag: %v", routes)
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	routes := []Route{
+		{},
+		{Name: "example.com"},
+		{Name: "*.example.com", Type: "aaaa"},
+		{Name: "{zone:hex}.example.com", Type: "TXT", Class: "CH"},
+		{Name: "{re:^shard[0-9]+$}.v5", Type: "*"},
+	}
+
+	router := NewRouter()
+	for i, r := range routes {
+		s := r.String()
+		p, err := ParseRoute(s)
+		if err != nil {
+			t.Errorf("bad case %d: %v", i+1, err)
+			continue
+		}
+		if y := p.String(); y != s {
+			t.Errorf("bad case %d: expected %q, got %q", i+1, s, y)
+		}
+		if _, err := router.HandleFunc(r, func(ResponseWriter, *Request) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var expected, y []string
+	for _, r := range routes {
+		expected = append(expected, r.String())
+	}
+	for _, r := range router.Routes() {
+		y = append(y, r.String())
+	}
+	sort.Strings(expected)
+	sort.Strings(y)
+	if !reflect.DeepEqual(y, expected) {
+		t.Errorf("expected routes %v, got %v", expected, y)
+	}
+
+	errors := []struct {
+		s      string
+		offset int
+		part   string
+	}{
+		{"", 0, ""},
+		{"example.com IN A", 12, "IN"},
+		{"example.com A IN x", 17, "x"},
+		{"{id:foo}.example.com", 0, "{id:foo}.example.com"},
+	}
+	for i, c := range errors {
+		_, err := ParseRoute(c.s)
+		e, ok := err.(*x.ParseError)
+		if !ok {
+			t.Errorf("bad error case %d: expected a parse error, got %v", i+1, err)
+			continue
+		}
+		if e.Offset != c.offset || e.Part != c.part {
+			t.Errorf("bad error case %d: expected %q at %d, got %q at %d", i+1, c.part, c.offset, e.Part, e.Offset)
+		}
+	}
+}
+
+func TestRouter_ServeDNS_CNAME(t *testing.T) {
+	router := NewRouter()
+	_, err := router.HandleFunc(Route{Name: "www.example.com", Type: "CNAME"}, func(w ResponseWriter, r *Request) {
+		cname := new(dns.CNAME)
+		cname.Hdr = dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET}
+		cname.Target = "example.com."
+		w.Answer(cname)
+		w.WriteMsg(r.Msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = router.HandleFunc(Route{Name: "example.com"}, func(w ResponseWriter, r *Request) {
+		a := new(dns.A)
+		a.Hdr = dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET}
+		w.Answer(a)
+		w.WriteMsg(r.Msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := &Request{
+		Msg: new(dns.Msg),
+	}
+	request.SetQuestion("www.example.com.", dns.TypeA)
+	w := new(responseWriter)
+	router.ServeDNS(w, request)
+
+	var answer []string
+	for _, rr := range w.msg.Answer {
+		answer = append(answer, rr.Header().Name+" "+dns.TypeToString[rr.Header().Rrtype])
+	}
+	if expected := []string{"www.example.com. CNAME", "example.com. A"}; !reflect.DeepEqual(answer, expected) {
+		t.Fatalf("expected %v, got %v", expected, answer)
+	}
+
+	if r, ok := router.Lookup(request.Msg); !ok || r.String() != "www.example.com CNAME IN" {
+		t.Fatalf("unexpected lookup %v %v", r, ok)
+	}
+}
+
+func TestRouter_Explain(t *testing.T) {
+	router := NewRouter()
+	handler := func(ResponseWriter, *Request) {}
+	for _, c := range []Route{
+		{Name: "example.com"},
+		{Name: "www.example.com", Type: "CNAME"},
+		{Name: "example.org", Type: "SOA"},
+		{Name: "example.net", Type: "MX"},
+	} {
+		if _, err := router.HandleFunc(c, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := router.UseFunc(Route{Name: "*.com"}, func(h Handler) Handler { return h }); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		handlers   []string
+		fallbacks  []string
+		middleware int
+	}{
+		{"example.com.", []string{"example.com A IN"}, nil, 1},
+		{"www.example.com.", []string{"www.example.com CNAME IN"}, []string{"CNAME"}, 0},
+		{"example.org.", []string{"example.org SOA IN"}, []string{"CNAME", "NS", "SOA"}, 0},
+		{"example.net.", nil, []string{"CNAME", "NS", "SOA", "NOERROR"}, 0},
+		{"example.io.", nil, nil, 0},
+	}
+	for i, c := range cases {
+		msg := new(dns.Msg)
+		msg.SetQuestion(c.name, dns.TypeA)
+		e, err := router.Explain(msg)
+		if err != nil {
+			t.Fatalf("bad case %d: %v", i+1, err)
+		}
+
+		var handlers, fallbacks []string
+		for _, r := range e.Handlers {
+			handlers = append(handlers, r.String())
+		}
+		for _, step := range e.Steps {
+			if step.Fallback != "" && (len(fallbacks) == 0 || fallbacks[len(fallbacks)-1] != step.Fallback) {
+				fallbacks = append(fallbacks, step.Fallback)
+			}
+		}
+		if !reflect.DeepEqual(handlers, c.handlers) {
+			t.Errorf("bad case %d for handlers: expected %v, got %v", i+1, c.handlers, handlers)
+		}
+		if !reflect.DeepEqual(fallbacks, c.fallbacks) {
+			t.Errorf("bad case %d for fallbacks: expected %v, got %v", i+1, c.fallbacks, fallbacks)
+		}
+		if len(e.Middleware) != c.middleware {
+			t.Errorf("bad case %d for middleware: expected %d, got %d", i+1, c.middleware, len(e.Middleware))
+		}
+	}
+
+	if _, err := router.Explain(new(dns.Msg)); err == nil {
+		t.Fatal("expected an error without questions")
+	}
+}
+
+func TestRouter_Analyze(t *testing.T) {
+	router := NewRouter()
+	handler := func(ResponseWriter, *Request) {}
+	for _, c := range []Route{
+		{Name: "www.example.com"},
+		{Name: "*.example.com"},
+		{Name: "{host}.example.com"},
+		{Name: "www.example.*"},
+		{Name: "www.example.com", Type: "AAAA"},
+	} {
+		if _, err := router.HandleFunc(c, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var issues []string
+	for _, issue := range router.Analyze() {
+		issues = append(issues, issue.String())
+	}
+	sort.Strings(issues)
+
+	expected := []string{
+		"www.example.* A IN overlaps *.example.com A IN ambiguously",
+		"www.example.* A IN overlaps {host}.example.com A IN ambiguously",
+		"{host}.example.com A IN duplicates *.example.com A IN",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("expected %q, got %q", expected, issues)
+	}
+}
+
+func TestRouter_AnalyzePools(t *testing.T) {
+	router := NewRouter()
+	h := HandlerFunc(func(ResponseWriter, *Request) {})
+	same := RefusedErrorHandler
+	for _, c := range []struct {
+		name string
+		h    Handler
+		opts []HandleOption
+	}{
+		{"pool.example.com", h, []HandleOption{WithWeight(x.NewWeight("blue", 1))}},
+		{"pool.example.com", h, []HandleOption{WithWeight(x.NewWeight("green", 1))}},
+		{"same.example.com", same, []HandleOption{WithWeight(x.NewWeight("blue", 1))}},
+		{"same.example.com", same, []HandleOption{WithWeight(x.NewWeight("green", 1))}},
+		{"plain.example.com", h, nil},
+		{"plain.example.com", h, nil},
+	} {
+		if _, err := router.Handle(Route{Name: c.name}, c.h, c.opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var issues []string
+	for _, issue := range router.Analyze() {
+		issues = append(issues, issue.String())
+	}
+	sort.Strings(issues)
+
+	expected := []string{
+		"plain.example.com A IN is registered more than once",
+		"same.example.com A IN is registered more than once",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("expected %q, got %q", expected, issues)
+	}
+}
+
 func BenchmarkMux(b *testing.B) {
 	router := NewRouter()
 	handler := func(w ResponseWriter, r *Request) {}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/vegertar/mux/x"
+)
+
+// Issue is a problem of routes reported by `Router.Analyze`.
+type Issue struct {
+	Kind x.IssueKind
+	// Route has the issue caused by Other, which is the same as Route if registered more than once.
+	Route, Other Route
+}
+
+// String returns the string representation.
+func (i Issue) String() string {
+	return i.Kind.Format(i.Route.String(), i.Other.String())
+}
+
+// Analyze reports unreachable, ambiguous and duplicate routes with handlers, see `x.Router.Analyze`.
+// Handlers registered more than once on a route are not duplicates if they are a pool by `Balancer`
+// or `WithWeight`, or a chain by `ChainPolicy` or `WithChain`, unless the same handler is registered again.
+func (p *Router) Analyze() []Issue {
+	var out []Issue
+	for _, v := range p.Router.Analyze(p.duplicated) {
+		out = append(out, Issue{v.Kind, labelRoute(v.Label), labelRoute(v.Other)})
+	}
+	return out
+}
+
+// duplicated reports if handlers of a label are registered more than once unintentionally.
+func (p *Router) duplicated(label *x.Label[http.Handler, Middleware]) bool {
+	if p.Balancer == nil && chainPolicy(label.Handler, p.ChainPolicy) == ChainDefault && !label.Weighted() {
+		return true
+	}
+	return label.SameHandlers()
+}
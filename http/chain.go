@@ -0,0 +1,207 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/vegertar/mux/x"
+)
+
+// ChainPolicy decides how multiple handlers of a route share a response.
+type ChainPolicy int
+
+const (
+	// ChainDefault uses the policy of the router, which is ChainAll if also ChainDefault.
+	ChainDefault ChainPolicy = iota
+	// ChainAll runs all handlers in order on the same response.
+	ChainAll
+	// ChainFirstWrite runs all handlers in order, but only the first handler that writes
+	// the response wins, writes of subsequent handlers are discarded.
+	ChainFirstWrite
+	// ChainCommitted runs handlers in order until the response is committed,
+	// i.e. the header is written, the body is written or flushed, or the connection is hijacked.
+	ChainCommitted
+	// ChainNext runs the first handler only, and each handler hands off to the next one by calling `Next`.
+	ChainNext
+)
+
+// Next calls the rest handlers of a route with the `ChainNext` policy, it does nothing otherwise.
+func Next(w http.ResponseWriter, r *http.Request) {
+	if next, ok := r.Context().Value(nextKey).(func(http.ResponseWriter, *http.Request)); ok {
+		next(w, r)
+	}
+}
+
+// Chain returns a handler running handlers by the given policy.
+func (m MultiHandler) Chain(policy ChainPolicy) http.Handler {
+	if len(m) <= 1 || policy == ChainDefault || policy == ChainAll {
+		return m
+	}
+	return &chain{handlers: m, policy: policy}
+}
+
+type chain struct {
+	handlers []http.Handler
+	policy   ChainPolicy
+}
+
+// ServeHTTP implements the `http.Handler` interface.
+func (c *chain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.policy == ChainNext {
+		c.next(0, w, r)
+		return
+	}
+
+	cw := &chainWriter{ResponseWriter: w}
+	for _, h := range c.handlers {
+		h.ServeHTTP(cw, r)
+		if cw.committed {
+			if c.policy == ChainCommitted {
+				return
+			}
+			cw.discard = true
+		}
+	}
+}
+
+func (c *chain) next(i int, w http.ResponseWriter, r *http.Request) {
+	if i >= len(c.handlers) {
+		return
+	}
+
+	next := func(w http.ResponseWriter, r *http.Request) {
+		c.next(i+1, w, r)
+	}
+	c.handlers[i].ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), nextKey, next)))
+}
+
+// chainWriter records if the response is committed, and discards writes if required.
+type chainWriter struct {
+	http.ResponseWriter
+
+	committed bool
+	discard   bool
+	header    http.Header
+}
+
+// Header implements the `http.ResponseWriter` interface.
+func (w *chainWriter) Header() http.Header {
+	if w.discard {
+		if w.header == nil {
+			w.header = make(http.Header)
+		}
+		return w.header
+	}
+	return w.ResponseWriter.Header()
+}
+
+// WriteHeader implements the `http.ResponseWriter` interface.
+func (w *chainWriter) WriteHeader(code int) {
+	if w.discard {
+		return
+	}
+	w.committed = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements the `http.ResponseWriter` interface.
+func (w *chainWriter) Write(b []byte) (int, error) {
+	if w.discard {
+		return len(b), nil
+	}
+	w.committed = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements the `http.Flusher` interface.
+func (w *chainWriter) Flush() {
+	if w.discard {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.committed = true
+		f.Flush()
+	}
+}
+
+// Hijack implements the `http.Hijacker` interface.
+func (w *chainWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if w.discard || !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.committed = true
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer for `http.ResponseController`.
+func (w *chainWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// optionHandler is a handler added with options.
+type optionHandler struct {
+	http.Handler
+	handleOptions
+}
+
+// wrap returns a handler carrying the options if any.
+func (o handleOptions) wrap(h http.Handler) http.Handler {
+	if o.chain != ChainDefault || o.weight != nil {
+		h = &optionHandler{Handler: h, handleOptions: o}
+	}
+	return h
+}
+
+// Weight implements the `x.Weighted` interface.
+func (h *optionHandler) Weight() *x.Weight {
+	return h.weight
+}
+
+// Unwrap implements the `x.Unwrapper` interface.
+func (h *optionHandler) Unwrap() http.Handler {
+	return h.Handler
+}
+
+// chainPolicy returns the policy of the first handler registered with one, or the fallback.
+func chainPolicy(handlers []http.Handler, fallback ChainPolicy) ChainPolicy {
+	for _, h := range handlers {
+		if v, ok := h.(*optionHandler); ok && v.chain != ChainDefault {
+			return v.chain
+		}
+	}
+	return fallback
+}
+
+// HandleOption configures a handler added by `Router.Handle`.
+type HandleOption func(*handleOptions)
+
+type handleOptions struct {
+	chain  ChainPolicy
+	weight *x.Weight
+	meta   *x.Meta
+}
+
+// WithChain sets the chain policy of the route, which overrides the one of the router.
+// If handlers of a route are added with different policies, the first one wins.
+func WithChain(policy ChainPolicy) HandleOption {
+	return func(o *handleOptions) {
+		o.chain = policy
+	}
+}
+
+// WithMeta sets the metadata of the handler, which is returned by `Router.Routes`.
+func WithMeta(meta x.Meta) HandleOption {
+	return func(o *handleOptions) {
+		o.meta = &meta
+	}
+}
+
+// WithWeight sets the weight of the handler in the pool of the route, which is used by `Router.Balancer`.
+func WithWeight(w *x.Weight) HandleOption {
+	return func(o *handleOptions) {
+		o.weight = w
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy configures the Cross-Origin Resource Sharing of routes.
+type CORSPolicy struct {
+	// AllowOrigins is a list of allowed origins, "*" allows any origin.
+	AllowOrigins []string
+	// AllowMethods is a list of methods for preflight requests,
+	// if empty the methods derived from registered routes are used.
+	AllowMethods []string
+	// AllowHeaders is a list of request headers for preflight requests,
+	// if empty the requested headers are reflected.
+	AllowHeaders []string
+	// ExposeHeaders is a list of response headers exposed to clients.
+	ExposeHeaders []string
+	// AllowCredentials tells if requests can include user credentials.
+	AllowCredentials bool
+	// MaxAge tells how long a preflight result can be cached.
+	MaxAge time.Duration
+}
+
+func (p CORSPolicy) allowOrigin(origin string) (string, bool) {
+	for _, v := range p.AllowOrigins {
+		if v == "*" {
+			if p.AllowCredentials {
+				// wildcard is not allowed with credentials
+				return origin, true
+			}
+			return v, true
+		}
+		if strings.EqualFold(v, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// CORS returns a middleware which applies the policy to cross-origin requests.
+// Preflight requests are answered directly, which should be attached to routes
+// with any method, e.g. `router.Use(Route{Path: "/api/**"}, CORS(policy))`.
+func CORS(policy CORSPolicy) Middleware {
+	return MiddlewareFunc(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Add("Vary", "Origin")
+			allowOrigin, ok := policy.allowOrigin(origin)
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Origin", allowOrigin)
+			if policy.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			method := r.Header.Get("Access-Control-Request-Method")
+			if r.Method != http.MethodOptions || method == "" {
+				if len(policy.ExposeHeaders) > 0 {
+					header.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposeHeaders, ", "))
+				}
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+
+			methods := policy.AllowMethods
+			if len(methods) == 0 {
+				methods = AllowedMethods(r)
+			}
+			if len(methods) == 0 {
+				methods = []string{strings.ToUpper(method)}
+			}
+			header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+			if len(policy.AllowHeaders) > 0 {
+				header.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowHeaders, ", "))
+			} else if v := r.Header.Get("Access-Control-Request-Headers"); v != "" {
+				header.Set("Access-Control-Allow-Headers", v)
+			}
+
+			if policy.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/vegertar/mux/x"
+)
+
+// Explanation is the decision path of matching a request, see `Router.Explain`.
+type Explanation struct {
+	// Steps are nodes visited in order, in which levels are scheme, method, host, port, path,
+	// headers and query.
+	Steps []x.Step
+	// Handler is the route whose handlers handle the request, or nil if none is matched.
+	Handler *Route
+	// Middleware are routes whose middleware wrap the handlers, from the outermost to the innermost.
+	Middleware []Route
+	// Allow are methods able to handle the request if no handler is matched by the request method.
+	Allow []string
+	// NotFound is the subtree-scoped NotFound route which handles the request if no route is matched.
+	NotFound *Route
+	// Redirect is the path to which the request is redirected if any.
+	Redirect string
+}
+
+// Explain tells how a request is matched, i.e. every candidate edge tried at each level,
+// and which routes contribute handlers and middleware.
+func (p *Router) Explain(req *http.Request) (*Explanation, error) {
+	route, path, err := p.requestRoute(req, p.source(req))
+	if err != nil {
+		return nil, err
+	}
+
+	v := p.Router.Explain(route)
+	e := &Explanation{Steps: v.Steps}
+	if to := p.redirectPath(route, path, v.Labels); to != "" {
+		e.Redirect = to
+		return e, nil
+	}
+
+	labels := v.Labels
+	if len(labels) > 0 && len(labels[0].Handler) > 0 {
+		r := labelRoute(labels[0])
+		e.Handler = &r
+	} else if e.Allow = p.allowedMethods(route); len(e.Allow) == 0 {
+		if fallbacks := p.fallbacks.Match(route); len(fallbacks) > 0 && len(fallbacks[0].Handler) > 0 {
+			r := labelRoute(fallbacks[0])
+			e.NotFound = &r
+			labels = append(labels, fallbacks...)
+		}
+	}
+
+	for _, label := range labels {
+		if len(label.Middleware) > 0 {
+			e.Middleware = append(e.Middleware, labelRoute(label))
+		}
+	}
+	return e, nil
+}
+
+// labelRoute returns the route of a label along with its metadata.
+func labelRoute(label *x.Label[http.Handler, Middleware]) Route {
+	r := routeFromKeys(label.Route())
+	r.Meta = label.Meta
+	return r
+}
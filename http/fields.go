@@ -0,0 +1,226 @@
+package http
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/vegertar/mux/x"
+	"github.com/vegertar/mux/x/radix"
+)
+
+// fieldSeparator separates `name:value` lines in a fields label.
+const fieldSeparator = "\n"
+
+var (
+	// fieldEscaper escapes separators in names and values, so that a value containing `\n` or `:`
+	// cannot be taken as another field.
+	fieldEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`, ":", `\:`)
+	fieldUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\:`, ":")
+)
+
+type field struct {
+	name  string
+	value radix.Label
+}
+
+// fieldsLabel is the label matched by name-value pairs, e.g. headers or query parameters.
+// It is matched against sorted `name:value` lines, a field matches if any line has the same
+// name and a value matched by the value pattern.
+type fieldsLabel struct {
+	s      string
+	fields []field
+}
+
+// String implements the `radix.Label` interface.
+func (p *fieldsLabel) String() string {
+	return p.s
+}
+
+// Match implements the `radix.Label` interface.
+func (p *fieldsLabel) Match(s string) bool {
+	for _, f := range p.fields {
+		if _, ok := f.find(s); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Literal implements the `radix.Label` interface.
+func (p *fieldsLabel) Literal() bool {
+	return false
+}
+
+// Wildcards implements the `radix.Label` interface.
+func (p *fieldsLabel) Wildcards() bool {
+	return false
+}
+
+// Overlap implements the `radix.Overlapper` interface. Fields compared by names overlap unless a
+// common name has values never matched together, requests repeating a name are not considered.
+func (p *fieldsLabel) Overlap(y radix.Label) bool {
+	q, ok := y.(*fieldsLabel)
+	if !ok {
+		// e.g. the glob matching any fields
+		return !y.Literal() || p.Match(y.String())
+	}
+	for _, f := range p.fields {
+		for _, g := range q.fields {
+			if f.name == g.name && !(radix.Key{f.value}).Overlap(radix.Key{g.value}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// find returns the first value in s matched by the field.
+func (f field) find(s string) (string, bool) {
+	prefix := fieldEscaper.Replace(f.name) + ":"
+	for s != "" {
+		line := s
+		if i := strings.Index(s, fieldSeparator); i >= 0 {
+			line, s = s[:i], s[i+len(fieldSeparator):]
+		} else {
+			s = ""
+		}
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		if value := fieldUnescaper.Replace(line[len(prefix):]); f.value.Match(value) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// newFieldsKey creates a key matched by all given name-value patterns.
+// If m is empty, the key matches anything.
+func newFieldsKey(m map[string]string, lowerName bool) (radix.Key, error) {
+	if len(m) == 0 {
+		return x.NewGlobSliceKey(globSlice)
+	}
+
+	p := new(fieldsLabel)
+	lines := make([]string, 0, len(m))
+	for name, value := range m {
+		if lowerName {
+			name = strings.ToLower(name)
+		}
+		label, err := x.NewLabel(value)
+		if err != nil {
+			return nil, err
+		}
+		p.fields = append(p.fields, field{name: name, value: label})
+	}
+	sort.Slice(p.fields, func(i, j int) bool {
+		return p.fields[i].name < p.fields[j].name
+	})
+	for _, f := range p.fields {
+		lines = append(lines, fieldEscaper.Replace(f.name)+":"+fieldEscaper.Replace(f.value.String()))
+	}
+	p.s = strings.Join(lines, fieldSeparator)
+	return radix.Key{p}, nil
+}
+
+// newLiteralFieldsKey creates a literal key from name-value pairs of a request.
+func newLiteralFieldsKey(m map[string][]string, lowerName bool) (radix.Key, error) {
+	type pair struct {
+		name, value string
+	}
+
+	pairs := make([]pair, 0, len(m))
+	for name, values := range m {
+		if lowerName {
+			name = strings.ToLower(name)
+		}
+		for _, value := range values {
+			pairs = append(pairs, pair{name, value})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].name != pairs[j].name {
+			return pairs[i].name < pairs[j].name
+		}
+		return pairs[i].value < pairs[j].value
+	})
+
+	lines := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		lines = append(lines, fieldEscaper.Replace(p.name)+":"+fieldEscaper.Replace(p.value))
+	}
+	return x.NewStringSliceKey([]string{strings.Join(lines, fieldSeparator)})
+}
+
+// captureFields returns the values matched by patterned fields of key, and binds named fields.
+func captureFields(key radix.Key, s string, bind func(name, value string)) []string {
+	p, ok := key[0].(*fieldsLabel)
+	if !ok {
+		return nil
+	}
+
+	var captures []string
+	for _, f := range p.fields {
+		if f.value.Literal() {
+			continue
+		}
+		value, _ := f.find(s)
+		captures = append(captures, value)
+		if param, ok := f.value.(*radix.ParamLabel); ok {
+			bind(param.Name(), value)
+		}
+	}
+	return captures
+}
+
+// fieldsMap returns the name-value patterns of a key created by `newFieldsKey`.
+func fieldsMap(key radix.Key) map[string]string {
+	p, ok := key[0].(*fieldsLabel)
+	if !ok {
+		return nil
+	}
+
+	m := make(map[string]string, len(p.fields))
+	for _, f := range p.fields {
+		m[f.name] = f.value.String()
+	}
+	return m
+}
+
+// formatFields returns the sorted name-value patterns joined by separators, e.g. `a=1&b=2`.
+// Names and values are quoted if they contain spaces or separators, see `quoteField`.
+func formatFields(m map[string]string, lowerName bool, pairSeparator, separator string) string {
+	pairs := make([]string, 0, len(m))
+	for name, value := range m {
+		if lowerName {
+			name = strings.ToLower(name)
+		}
+		pairs = append(pairs, quoteField(name, pairSeparator+separator)+pairSeparator+quoteField(value, separator))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, separator)
+}
+
+// quoteField returns s as a Go string literal if it's empty, or it contains spaces, double quotes,
+// non-printable characters, any of special characters or unbalanced braces, otherwise s as is.
+func quoteField(s, special string) string {
+	depth := 0
+	for _, c := range s {
+		switch {
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth--; depth < 0 {
+				return strconv.Quote(s)
+			}
+		case c == ' ' || c == '"' || !unicode.IsPrint(c) || strings.ContainsRune(special, c):
+			return strconv.Quote(s)
+		}
+	}
+	if s == "" || depth != 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -3,8 +3,10 @@ package http
 import (
 	"context"
 	"net/http"
+	"net/url"
 
 	"github.com/vegertar/mux/x"
+	"github.com/vegertar/mux/x/radix"
 )
 
 // MultiHandler is a wrapper of multiple HTTP handlers.
@@ -17,11 +19,11 @@ func (m MultiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func newMultiHandler(handler ...interface{}) MultiHandler {
+func newMultiHandler(handler ...http.Handler) MultiHandler {
 	m := make([]http.Handler, 0, len(handler))
 	for _, v := range handler {
 		if v != nil {
-			m = append(m, v.(http.Handler))
+			m = append(m, v)
 		}
 	}
 	if len(m) == 0 {
@@ -30,19 +32,19 @@ func newMultiHandler(handler ...interface{}) MultiHandler {
 	return MultiHandler(m)
 }
 
-func newHandlerFromLabels(route x.Route, labels []*x.Label) http.Handler {
+func (p *Router) newHandlerFromLabels(route x.Route, labels []*x.Label[http.Handler, Middleware], fallback http.Handler) http.Handler {
 	var (
-		h = notFound
+		h = fallback
 
-		handlers   []interface{}
-		middleware []interface{}
+		handlers   []http.Handler
+		middleware []Middleware
 	)
 
 	if len(labels) > 0 {
 		// remains the first matched handlers only
 		handlers = append(handlers, labels[0].Handler...)
 		// extracts request variables
-		middleware = append(middleware, getVars(route, labels[0]))
+		middleware = append(middleware, getVars(route, labels[0], p.UseEscapedPath))
 		// adds ordinary middleware
 		for _, label := range labels {
 			middleware = append(middleware, label.Middleware...)
@@ -50,36 +52,83 @@ func newHandlerFromLabels(route x.Route, labels []*x.Label) http.Handler {
 	}
 
 	if len(handlers) > 0 {
-		h = newMultiHandler(handlers...)
+		if p.Balancer != nil {
+			h = p.pool(labels[0].Handler, labels[0].Weights)
+		} else {
+			h = newMultiHandler(handlers...).Chain(chainPolicy(handlers, p.ChainPolicy))
+		}
 	}
 
 	for i := range middleware {
 		if m := middleware[len(middleware)-1-i]; m != nil {
-			h = m.(Middleware).GenerateHandler(h)
+			h = m.GenerateHandler(h)
 		}
 	}
 
 	if h == nil {
-		h = notFound
+		h = fallback
 	}
 	return h
 }
 
-func getVars(route x.Route, label *x.Label) Middleware {
+// getVars extracts request variables, path values are unescaped if the route is matched by an escaped path.
+func getVars(route x.Route, label *x.Label[http.Handler, Middleware], escaped bool) Middleware {
 	return MiddlewareFunc(func(h http.Handler) http.Handler {
 		var varsValue VarsValue
 
-		pathKey := label.Key
-		varsValue.Path = append(varsValue.Path, pathKey.StringWith("/"))
-		for _, k := range pathKey.Capture(route[len(route)-1]) {
-			varsValue.Path = append(varsValue.Path, k.StringWith("/"))
-		}
+		keys := matchedKeys(route, label)
 
-		hostKey := label.Node.Up().Key
+		hostKey := keys[hostLevel]
 		varsValue.Host = append(varsValue.Host, hostKey.StringWith("."))
-		for _, k := range hostKey.Capture(route[len(route)-2]) {
+		for _, k := range hostKey.Capture(route[hostLevel]) {
 			varsValue.Host = append(varsValue.Host, k.StringWith("."))
 		}
+		varsValue.bind(hostKey, route[hostLevel], ".")
+
+		portKey := keys[portLevel]
+		varsValue.Port = append(varsValue.Port, portKey.StringWith(""))
+		for _, k := range portKey.Capture(route[portLevel]) {
+			varsValue.Port = append(varsValue.Port, k.StringWith(""))
+		}
+		varsValue.bind(portKey, route[portLevel], "")
+
+		if m := fieldsMap(keys[queryLevel]); m != nil {
+			s := route[queryLevel][0].String()
+			varsValue.Query = append(varsValue.Query, formatFields(m, false, "=", "&"))
+			varsValue.Query = append(varsValue.Query, captureFields(keys[queryLevel], s, varsValue.set)...)
+		}
+
+		if m := fieldsMap(keys[headerLevel]); m != nil {
+			s := route[headerLevel][0].String()
+			varsValue.Header = append(varsValue.Header, formatFields(m, false, ":", " "))
+			varsValue.Header = append(varsValue.Header, captureFields(keys[headerLevel], s, varsValue.set)...)
+		}
+
+		pathKey := keys[pathLevel]
+		varsValue.Path = append(varsValue.Path, pathKey.StringWith("/"))
+		for _, k := range pathKey.Capture(route[pathLevel]) {
+			varsValue.Path = append(varsValue.Path, k.StringWith("/"))
+		}
+		varsValue.RawPath = varsValue.Path
+		if escaped {
+			varsValue.Path = make([]string, 0, len(varsValue.RawPath))
+			varsValue.Path = append(varsValue.Path, varsValue.RawPath[0])
+			for _, s := range varsValue.RawPath[1:] {
+				varsValue.Path = append(varsValue.Path, unescapePath(s))
+			}
+
+			var raw VarsValue
+			raw.bind(pathKey, route[pathLevel], "/")
+			for name, value := range raw.Params {
+				varsValue.set(name, unescapePath(value))
+				if varsValue.RawParams == nil {
+					varsValue.RawParams = make(map[string]string)
+				}
+				varsValue.RawParams[name] = value
+			}
+		} else {
+			varsValue.bind(pathKey, route[pathLevel], "/")
+		}
 
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), varsKey, varsValue)))
@@ -87,6 +136,23 @@ func getVars(route x.Route, label *x.Label) Middleware {
 	})
 }
 
+// unescapePath unescapes a path value, it's kept as is if malformed.
+func unescapePath(s string) string {
+	if v, err := url.PathUnescape(s); err == nil {
+		return v
+	}
+	return s
+}
+
+// matchedKeys collects pattern keys of all levels from a matched leaf label.
+func matchedKeys(route x.Route, label *x.Label[http.Handler, Middleware]) []radix.Key {
+	keys := make([]radix.Key, len(route))
+	for i, up := len(keys)-1, label; i >= 0 && up != nil; i, up = i-1, up.Node.Up() {
+		keys[i] = up.Key
+	}
+	return keys
+}
+
 // Vars returns the route variables for the current request.
 func Vars(r *http.Request) VarsValue {
 	if v := r.Context().Value(varsKey); v != nil {
@@ -98,15 +164,70 @@ func Vars(r *http.Request) VarsValue {
 type (
 	contextKey int
 
-	// VarsValue is the value of positional patterns.
+	// VarsValue is the value of positional and named patterns.
 	VarsValue struct {
 		// Host is the value of host patterns in which [0] is the entire pattern, [1] is the first field, etc.
 		Host []string
+		// Port is the value of port patterns in which [0] is the entire pattern, [1] is the captured port if patterned.
+		Port []string
 		// Path is the value of path patterns in which [0] is the entire pattern, [1] is the first field, etc.
+		// Values are unescaped if the router matches escaped paths.
 		Path []string
+		// RawPath is the escaped value of path patterns if the router matches escaped paths,
+		// otherwise it's the same as Path.
+		RawPath []string
+		// Header is the value of header patterns in which [0] is the entire pattern,
+		// [1] is the first patterned header, etc. It's nil if the route requires no headers.
+		Header []string
+		// Query is the value of query patterns in which [0] is the entire pattern,
+		// [1] is the first patterned query parameter, etc. It's nil if the route requires no query.
+		Query []string
+		// Params is the value of named fields, e.g. `{id}` or `:id`, in all patterns.
+		Params map[string]string
+		// RawParams is the escaped value of named fields in path if the router matches escaped paths.
+		RawParams map[string]string
 	}
 )
 
+// Get returns the value of a named field, a field in path overrides the same name in headers,
+// headers override query, query overrides port, and port overrides host.
+func (v VarsValue) Get(name string) string {
+	return v.Params[name]
+}
+
+// GetRaw returns the escaped value of a named field in path if the router matches escaped paths,
+// otherwise it's the same as `Get`.
+func (v VarsValue) GetRaw(name string) string {
+	if s, ok := v.RawParams[name]; ok {
+		return s
+	}
+	return v.Params[name]
+}
+
+func (v *VarsValue) bind(pattern, key radix.Key, separator string) {
+	for i, k := range pattern.Align(key) {
+		if p, ok := pattern[i].(*radix.ParamLabel); ok {
+			v.set(p.Name(), k.StringWith(separator))
+		}
+	}
+}
+
+func (v *VarsValue) set(name, value string) {
+	if v.Params == nil {
+		v.Params = make(map[string]string)
+	}
+	v.Params[name] = value
+}
+
+// AllowedMethods returns the methods which are able to handle the requested route,
+// it's available only if no handler has been matched by the request method.
+func AllowedMethods(r *http.Request) []string {
+	if v := r.Context().Value(allowKey); v != nil {
+		return v.([]string)
+	}
+	return nil
+}
+
 const (
 	varsKey contextKey = iota
 
@@ -115,8 +236,17 @@ const (
 	// routine the handler. The associated value will be of
 	// type *Router.
 	RouterContextKey
+
+	allowKey
+	sourceKey
+	nextKey
+	pinsKey
 )
 
 var (
 	notFound = http.NotFoundHandler()
+
+	methodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	})
 )
@@ -0,0 +1,299 @@
+package http
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/vegertar/mux/x"
+	"github.com/vegertar/mux/x/radix"
+)
+
+// ParseRoute parses a route from the representation returned by `Route.String`, i.e.
+// `METHOD scheme://host[:port]/path[?name=value&...] [Name:value ...]`, e.g.
+// `GET https://*.example.com/api/**?format=json X-Api-Version:2*`. Unspecified parts are
+// written as `*` or `**`. Hosts, paths, names and values of headers and query parameters are written
+// as Go string literals if they contain spaces or separators, e.g. `GET *://**/"v?/x"` or
+// `Accept:"text/html, */*"`. The result of parsing `r.String()` is equivalent to r, and its
+// string representation is the same as `r.String()`.
+func ParseRoute(s string) (Route, error) {
+	var r Route
+
+	p := routeParser{input: s}
+	fields := splitTop(s, 0, ' ')
+	if len(fields) < 2 {
+		return r, p.errorf(len(s), "", "missing method or URL")
+	}
+
+	method := fields[0]
+	if method.s != glob {
+		r.Method = method.s
+	}
+	if err := p.check(method, func() error {
+		_, err := x.NewLabel(method.s)
+		return err
+	}); err != nil {
+		return r, err
+	}
+
+	u := fields[1]
+	i := strings.Index(u.s, "://")
+	if i <= 0 {
+		return r, p.errorf(u.offset, u.s, "missing scheme")
+	}
+	scheme := part{u.s[:i], u.offset}
+	if scheme.s != glob {
+		r.Scheme = scheme.s
+	}
+	if err := p.check(scheme, func() error {
+		_, err := x.NewLabel(scheme.s)
+		return err
+	}); err != nil {
+		return r, err
+	}
+
+	rest := part{u.s[i+3:], u.offset + i + 3}
+	if q := indexTop(rest.s, '?'); q >= 0 {
+		query := part{rest.s[q+1:], rest.offset + q + 1}
+		rest.s = rest.s[:q]
+		m, err := p.fields(query, '&', '=')
+		if err != nil {
+			return r, err
+		}
+		r.Query = m
+	}
+
+	j := indexTop(rest.s, '/')
+	if j < 0 {
+		return r, p.errorf(rest.offset, rest.s, "missing path")
+	}
+	host, port := splitHostPortPattern(part{rest.s[:j], rest.offset})
+	path := part{rest.s[j+1:], rest.offset + j + 1}
+
+	if host.s == "" {
+		return r, p.errorf(host.offset, host.s, "missing host")
+	}
+	hostValue, err := p.unquote(host)
+	if err != nil {
+		return r, err
+	}
+	if host.s != wildcards {
+		r.Host = hostValue
+	}
+	if err := p.check(host, func() error {
+		_, err := x.NewGlobSliceKey(lowerFields(trimBrackets(hostValue), "."))
+		return err
+	}); err != nil {
+		return r, err
+	}
+
+	if port.offset >= 0 {
+		if port.s == "" {
+			return r, p.errorf(port.offset, port.s, "missing port")
+		}
+		r.Port = port.s
+		if err := p.check(port, func() error {
+			_, err := x.NewLabel(port.s)
+			return err
+		}); err != nil {
+			return r, err
+		}
+	}
+
+	pathValue, err := p.unquote(path)
+	if err != nil {
+		return r, err
+	}
+	if path.s != wildcards {
+		r.Path = "/" + pathValue
+	}
+	if err := p.check(path, func() error {
+		_, err := x.NewGlobSliceKey(radix.SplitPattern(pathValue, "/"))
+		return err
+	}); err != nil {
+		return r, err
+	}
+
+	for _, f := range fields[2:] {
+		m, err := p.fields(f, 0, ':')
+		if err != nil {
+			return r, err
+		}
+		if r.Headers == nil {
+			r.Headers = make(map[string]string)
+		}
+		for k, v := range m {
+			r.Headers[k] = v
+		}
+	}
+
+	return r, nil
+}
+
+// part is a substring with the offset in the input.
+type part struct {
+	s      string
+	offset int
+}
+
+type routeParser struct {
+	input string
+}
+
+func (p routeParser) errorf(offset int, s, reason string) error {
+	return &x.ParseError{Input: p.input, Offset: offset, Part: s, Err: errors.New(reason)}
+}
+
+func (p routeParser) check(v part, f func() error) error {
+	if err := f(); err != nil {
+		return &x.ParseError{Input: p.input, Offset: v.offset, Part: v.s, Err: err}
+	}
+	return nil
+}
+
+// unquote returns v unquoted if it's a Go string literal, otherwise v as is.
+func (p routeParser) unquote(v part) (string, error) {
+	if !strings.HasPrefix(v.s, `"`) {
+		return v.s, nil
+	}
+	s, err := strconv.Unquote(v.s)
+	if err != nil {
+		return "", p.check(v, func() error { return err })
+	}
+	return s, nil
+}
+
+// fields parses name-value pairs separated by separator, e.g. `a=1&b=2`.
+// If separator is 0, v is a single pair.
+func (p routeParser) fields(v part, separator, pairSeparator byte) (map[string]string, error) {
+	pairs := []part{v}
+	if separator != 0 {
+		pairs = splitTop(v.s, v.offset, separator)
+	}
+
+	m := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		var (
+			name string
+			i    int
+		)
+		if strings.HasPrefix(pair.s, `"`) {
+			q, err := strconv.QuotedPrefix(pair.s)
+			if err != nil {
+				return nil, p.check(pair, func() error { return err })
+			}
+			name, _ = strconv.Unquote(q)
+			if i = len(q); i == len(pair.s) || pair.s[i] != pairSeparator {
+				return nil, p.errorf(pair.offset, pair.s, "missing name or value")
+			}
+		} else if i = strings.IndexByte(pair.s, pairSeparator); i > 0 {
+			name = pair.s[:i]
+		} else {
+			return nil, p.errorf(pair.offset, pair.s, "missing name or value")
+		}
+
+		value := part{pair.s[i+1:], pair.offset + i + 1}
+		s, err := p.unquote(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.check(value, func() error {
+			_, err := x.NewLabel(s)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		m[name] = s
+	}
+	return m, nil
+}
+
+// splitHostPortPattern splits a host pattern and an optional port pattern, the offset of port is -1 if absent.
+// Colons within braces, quotes or leading a host field, e.g. `:tenant`, are not separators.
+func splitHostPortPattern(v part) (host, port part) {
+	port.offset = -1
+	if strings.HasPrefix(v.s, `"`) {
+		if q, err := strconv.QuotedPrefix(v.s); err == nil {
+			host = part{q, v.offset}
+			if rest := v.s[len(q):]; strings.HasPrefix(rest, ":") {
+				port = part{rest[1:], v.offset + len(q) + 1}
+			} else if rest != "" {
+				host = v
+			}
+			return host, port
+		}
+	}
+	if strings.HasPrefix(v.s, "[") {
+		i := strings.IndexByte(v.s, ']')
+		if i < 0 {
+			return v, port
+		}
+		host = part{v.s[:i+1], v.offset}
+		if rest := v.s[i+1:]; strings.HasPrefix(rest, ":") {
+			port = part{rest[1:], v.offset + i + 2}
+		}
+		return host, port
+	}
+	if net.ParseIP(v.s) != nil {
+		return v, port
+	}
+
+	depth := 0
+	for i := 0; i < len(v.s); i++ {
+		switch c := v.s[i]; {
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == ':' && depth == 0 && i > 0 && v.s[i-1] != '.':
+			return part{v.s[:i], v.offset}, part{v.s[i+1:], v.offset + i + 1}
+		}
+	}
+	return v, port
+}
+
+// indexTop returns the index of the first c out of braces and quoted names or values in s,
+// or -1 if absent.
+func indexTop(s string, c byte) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			// a quoted host, path, name or value leads a field or follows a separator
+			if i == 0 || strings.IndexByte(" /?&=:", s[i-1]) >= 0 {
+				if q, err := strconv.QuotedPrefix(s[i:]); err == nil {
+					i += len(q) - 1
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case c:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTop splits s by the separator out of braces, empty fields are skipped if the separator is a space.
+func splitTop(s string, offset int, separator byte) []part {
+	var out []part
+	for {
+		i := indexTop(s, separator)
+		if i < 0 {
+			break
+		}
+		if i > 0 || separator != ' ' {
+			out = append(out, part{s[:i], offset})
+		}
+		s, offset = s[i+1:], offset+i+1
+	}
+	if s != "" || separator != ' ' {
+		out = append(out, part{s, offset})
+	}
+	return out
+}
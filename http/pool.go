@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/vegertar/mux/x"
+)
+
+// pool returns a handler calling one of handlers selected by the balancer of the router,
+// weights are precomputed along with handlers of a label.
+func (p *Router) pool(handlers []http.Handler, weights []*x.Weight) http.Handler {
+	balancer, balanceKey := p.Balancer, p.BalanceKey
+	if balanceKey == nil {
+		balanceKey = remoteHost
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := x.Select(balancer, handlers, weights, balanceKey(r))
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		// releases references of handlers not selected
+		pinsFrom(r).Keep(inflightOf(h))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// remoteHost returns the host of the remote address of a request.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vegertar/mux/x"
+)
+
+// Registration is a handle of a handler added by `Router.Register`.
+type Registration struct {
+	*x.Registration[http.Handler]
+
+	options  handleOptions
+	inflight *x.Inflight
+}
+
+// Swap replaces the handler atomically, options of the registration are kept.
+func (r *Registration) Swap(h http.Handler) error {
+	return r.Registration.Swap(r.wrap(h))
+}
+
+// SwapMeta is like `Swap` along with metadata, a nil meta removes the metadata.
+func (r *Registration) SwapMeta(meta *x.Meta, h http.Handler) error {
+	return r.Registration.SwapMeta(meta, r.wrap(h))
+}
+
+// CloseContext unloads the handler like `Close`, then waits until in-flight requests dispatched to
+// the handler complete. A request is counted once the handler is selected by `Router.ServeHTTP`,
+// i.e. before middleware runs. It returns the error of the context if it's done before that.
+func (r *Registration) CloseContext(ctx context.Context) error {
+	r.Close()
+	return r.inflight.Wait(ctx)
+}
+
+// Inflight returns the number of in-flight requests dispatched to the handler.
+func (r *Registration) Inflight() int {
+	return r.inflight.Len()
+}
+
+func (r *Registration) wrap(h http.Handler) http.Handler {
+	return r.options.wrap(&trackedHandler{Handler: h, inflight: r.inflight})
+}
+
+// trackedHandler counts in-flight requests which are not pinned by the router, e.g. served by the
+// handler returned from `Router.Match`.
+type trackedHandler struct {
+	http.Handler
+	inflight *x.Inflight
+}
+
+// Unwrap implements the `x.Unwrapper` interface.
+func (h *trackedHandler) Unwrap() http.Handler {
+	return h.Handler
+}
+
+// ServeHTTP implements the `http.Handler` interface.
+func (h *trackedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !pinsFrom(r).Has(h.inflight) {
+		h.inflight.Enter()
+		defer h.inflight.Leave()
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// inflightOf returns the in-flight counter of a handler added by `Router.Register`, or nil if not.
+func inflightOf(h http.Handler) *x.Inflight {
+	if v, ok := h.(*optionHandler); ok {
+		h = v.Handler
+	}
+	if v, ok := h.(*trackedHandler); ok {
+		return v.inflight
+	}
+	return nil
+}
+
+// pin takes in-flight references of handlers, it returns false if any is draining.
+func pin(handlers []http.Handler) (*x.Pins, bool) {
+	pins := new(x.Pins)
+	for _, h := range handlers {
+		if in := inflightOf(h); in != nil && !pins.Add(in) {
+			pins.Release()
+			return nil, false
+		}
+	}
+	return pins, true
+}
+
+// pinsFrom returns the in-flight references taken for the request if any.
+func pinsFrom(r *http.Request) *x.Pins {
+	pins, _ := r.Context().Value(pinsKey).(*x.Pins)
+	return pins
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/vegertar/mux/x"
@@ -13,6 +14,18 @@ const (
 	wildcards = "**"
 )
 
+// levels of a route sequence
+const (
+	schemeLevel = iota
+	methodLevel
+	hostLevel
+	portLevel
+	pathLevel
+	headerLevel
+	queryLevel
+	numLevels
+)
+
 var (
 	globSlice      = []string{glob}
 	wildcardsSlice = []string{wildcards}
@@ -20,11 +33,22 @@ var (
 
 // Route is the HTTP route component configure.
 type Route struct {
-	Scheme     string
-	Method     string
-	Host       string
-	Path       string
+	Scheme string
+	Method string
+	Host   string
+	// Port is a pattern of the port, e.g. `80*` or `{port:int}`, which is matched
+	// against the request port or the default one of the scheme, i.e. 80 or 443.
+	Port string
+	Path string
+	// Headers are required request headers, in which names are case-insensitive
+	// and values are patterns, e.g. `{"X-Api-Version": "2*"}`.
+	Headers map[string]string
+	// Query are required query parameters, in which values are patterns, e.g. `{"format": "json"}`.
+	Query      map[string]string
 	UseLiteral bool
+	// Meta is metadata of handlers and middleware on the route, which is filled by `Router.Routes`
+	// and ignored when adding routes.
+	Meta []x.Meta
 }
 
 // String returns the string representation.
@@ -37,19 +61,41 @@ func (r Route) String() string {
 		method = strings.ToUpper(r.Method)
 	}
 	if len(r.Host) > 0 {
-		host = strings.ToLower(r.Host)
+		host = quoteField(strings.Join(lowerFields(trimBrackets(r.Host), "."), "."), "?/")
 	}
 	if len(r.Path) > 0 {
-		path = strings.TrimPrefix(strings.ToLower(r.Path), "/")
+		if path = strings.TrimPrefix(r.Path, "/"); path != "" {
+			path = quoteField(path, "?")
+		}
+	}
+	if len(r.Port) > 0 {
+		if strings.Contains(host, ":") && net.ParseIP(host) != nil {
+			host = "[" + host + "]"
+		}
+		host += ":" + r.Port
+	}
+	s := fmt.Sprintf("%s %s://%s/%s", method, scheme, host, path)
+	if len(r.Query) > 0 {
+		s += "?" + formatFields(r.Query, false, "=", "&")
 	}
-	return fmt.Sprintf("%s %s://%s/%s", method, scheme, host, path)
+	if len(r.Headers) > 0 {
+		s += " " + formatFields(r.Headers, true, ":", " ")
+	}
+	return s
 }
 
-func newRoute(r Route) (x.Route, error) {
-	v := make([]radix.Key, 0, 4)
-	f := x.NewGlobSliceKey
+// newRoute creates a route sequence, the path is kept as is if caseSensitive.
+func newRoute(r Route, caseSensitive bool) (x.Route, error) {
+	v := make([]radix.Key, 0, numLevels)
+	f, split, splitPath := x.NewGlobSliceKey, lowerFields, lowerFields
+	if caseSensitive {
+		splitPath = radix.SplitPattern
+	}
 	if r.UseLiteral {
-		f = x.NewStringSliceKey
+		f, split, splitPath = x.NewStringSliceKey, lowerLiteralFields, lowerLiteralFields
+		if caseSensitive {
+			splitPath = strings.Split
+		}
 	}
 
 	var (
@@ -78,7 +124,7 @@ func newRoute(r Route) (x.Route, error) {
 	v = append(v, key)
 
 	if len(r.Host) > 0 {
-		key, err = f(strings.Split(strings.ToLower(r.Host), "."))
+		key, err = f(split(trimBrackets(r.Host), "."))
 	} else {
 		key, err = f(wildcardsSlice)
 	}
@@ -87,8 +133,18 @@ func newRoute(r Route) (x.Route, error) {
 	}
 	v = append(v, key)
 
+	if len(r.Port) > 0 {
+		key, err = f([]string{r.Port})
+	} else {
+		key, err = f(globSlice)
+	}
+	if err != nil {
+		return nil, err
+	}
+	v = append(v, key)
+
 	if len(r.Path) > 0 {
-		key, err = f(strings.Split(strings.ToLower(r.Path), "/"))
+		key, err = f(splitPath(r.Path, "/"))
 	} else {
 		key, err = f(wildcardsSlice)
 	}
@@ -97,5 +153,46 @@ func newRoute(r Route) (x.Route, error) {
 	}
 	v = append(v, key)
 
+	fields := newFieldsKey
+	if r.UseLiteral {
+		fields = func(m map[string]string, lowerName bool) (radix.Key, error) {
+			m2 := make(map[string][]string, len(m))
+			for k, v := range m {
+				m2[k] = []string{v}
+			}
+			return newLiteralFieldsKey(m2, lowerName)
+		}
+	}
+
+	key, err = fields(r.Headers, true)
+	if err != nil {
+		return nil, err
+	}
+	v = append(v, key)
+
+	key, err = fields(r.Query, false)
+	if err != nil {
+		return nil, err
+	}
+	v = append(v, key)
+
 	return v, nil
 }
+
+// trimBrackets removes brackets enclosing an IPv6 literal, e.g. `[::1]`.
+func trimBrackets(host string) string {
+	if len(host) > 2 && host[0] == '[' && host[len(host)-1] == ']' && strings.Contains(host, ":") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// lowerFields splits a pattern into lower case fields, regular expressions are kept as is.
+func lowerFields(s, separator string) []string {
+	return x.MapPattern(radix.SplitPattern(s, separator), strings.ToLower)
+}
+
+// lowerLiteralFields splits a string literal into lower case fields.
+func lowerLiteralFields(s, separator string) []string {
+	return strings.Split(strings.ToLower(s), separator)
+}
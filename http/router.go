@@ -5,23 +5,88 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"strings"
+	"sync/atomic"
 
 	"github.com/vegertar/mux/x"
 )
 
 // Router is a wrapper of HTTP mux.
 type Router struct {
-	*x.Router
+	*x.Router[http.Handler, Middleware]
+
+	// MethodNotAllowed is called if the route is matched by other methods only,
+	// the `Allow` header is set before calling. If nil, a 405 error is replied.
+	MethodNotAllowed http.Handler
+
+	// NotFound is called if no route is matched and no subtree fallback applies. If nil, a 404 error is replied.
+	NotFound http.Handler
+
+	// CaseSensitive enables to match paths case-sensitively, hosts are always case-insensitive.
+	// It should be set before adding any route.
+	CaseSensitive bool
+
+	// HandleOPTIONS enables to answer OPTIONS requests automatically
+	// if the route is matched by other methods and there is no OPTIONS handler.
+	HandleOPTIONS bool
+
+	// TrustedProxies are networks of proxies in which the X-Forwarded-Proto, X-Forwarded-Host and
+	// Forwarded headers are honored to resolve the scheme and host of requests, see `Source`.
+	// Note that websocket requests resolved to "https", either by TLS or by forwarded headers, are
+	// matched with the scheme "wss" rather than "ws", so routes of them should use `wss` or `ws*`.
+	TrustedProxies []*net.IPNet
+
+	// RedirectTrailingSlash enables to redirect a request to the path with (without) the trailing slash
+	// if the route is missed but a handler exists for that path. It also applies if the trailing empty
+	// segment is matched by a pattern, e.g. `/v1/` by `/v1/*`, but a handler exists for `/v1`.
+	// GET and HEAD requests are redirected with 301, others are redirected with 308.
+	RedirectTrailingSlash bool
+
+	// RedirectFixedPath enables to redirect a request with empty, `.` or `..` segments, e.g. `/a//b/../c`,
+	// to the cleaned path if a handler exists for that path.
+	RedirectFixedPath bool
+
+	// UseEscapedPath enables to match the escaped path, i.e. `URL.EscapedPath()`, so that an encoded slash
+	// `%2F` is kept in a segment rather than a separator. Path values are unescaped after captured,
+	// the escaped ones are available by `VarsValue.RawPath` and `VarsValue.GetRaw`.
+	UseEscapedPath bool
+
+	// ChainPolicy decides how multiple handlers of a route share a response,
+	// which can be overridden per route by `WithChain`.
+	ChainPolicy ChainPolicy
+
+	// Balancer enables to treat multiple handlers of a route as a pool, only one handler selected by
+	// the balancer is called for each request, weights of handlers are set by `WithWeight`.
+	// If no handler is available, a 503 error is replied.
+	Balancer x.Balancer
+
+	// BalanceKey returns the key of a request for the balancer, e.g. for consistent hashing.
+	// If nil, the host of the remote address is used.
+	BalanceKey func(*http.Request) string
+
+	// headers and query tell if any route requires headers or query parameters,
+	// so that requests are able to skip building unnecessary keys.
+	headers, query atomic.Bool
+
+	// fallbacks are subtree-scoped handlers called if no route is matched
+	fallbacks *x.Router[http.Handler, Middleware]
 }
 
 // NewRouter creates an HTTP router.
 func NewRouter() *Router {
 	return &Router{
-		Router: &x.Router{
-			Breed: func(up *x.Label) x.Node {
-				return x.NewRadixNode(up)
-			},
+		Router:    newRadixRouter(),
+		fallbacks: newRadixRouter(),
+	}
+}
+
+func newRadixRouter() *x.Router[http.Handler, Middleware] {
+	return &x.Router[http.Handler, Middleware]{
+		Breed: func(up *x.Label[http.Handler, Middleware]) x.Node[http.Handler, Middleware] {
+			return x.NewRadixNode(up)
 		},
 	}
 }
@@ -30,48 +95,168 @@ func NewRouter() *Router {
 func (p *Router) Routes() []Route {
 	var out []Route
 
-	for _, route := range p.Router.Routes() {
-		var r Route
-		r.Scheme = route[0][0].String()
-		if len(route) > 1 {
-			r.Method = route[1][0].String()
-		}
-		if len(route) > 2 {
-			r.Host = route[2].StringWith(".")
-		}
-		if len(route) > 3 {
-			r.Path = route[3].StringWith("/")
-		}
+	routes, labels := p.Router.RouteLabels()
+	for i, route := range routes {
+		r := routeFromKeys(route)
+		r.Meta = labels[i].Meta
 		out = append(out, r)
 	}
 
 	return out
 }
 
+// routeFromKeys converts pattern keys of levels into a route.
+func routeFromKeys(route x.Route) Route {
+	var r Route
+	r.Scheme = route[schemeLevel][0].String()
+	if len(route) > methodLevel {
+		r.Method = route[methodLevel][0].String()
+	}
+	if len(route) > hostLevel {
+		r.Host = route[hostLevel].StringWith(".")
+	}
+	if len(route) > portLevel {
+		r.Port = route[portLevel][0].String()
+	}
+	if len(route) > pathLevel {
+		r.Path = route[pathLevel].StringWith("/")
+	}
+	if len(route) > headerLevel {
+		r.Headers = fieldsMap(route[headerLevel])
+	}
+	if len(route) > queryLevel {
+		r.Query = fieldsMap(route[queryLevel])
+	}
+	return r
+}
+
+// RoutesByTag returns registered route sequences which have metadata with the given tag.
+func (p *Router) RoutesByTag(tag string) []Route {
+	var out []Route
+	for _, r := range p.Routes() {
+		if x.HasTag(r.Meta, tag) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
 // Match returns an associated `http.Handle` by given route.
 func (p *Router) Match(c Route) http.Handler {
-	r, err := newRoute(c)
+	r, err := newRoute(c, p.CaseSensitive)
 	if err != nil {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			http.Error(w, err.Error(), 500)
 		})
 	}
-	return newHandlerFromLabels(r, p.Router.Match(r))
+	return p.match(r, p.Router.Match(r))
+}
+
+// match returns the handler of labels matched by the route.
+func (p *Router) match(r x.Route, labels []*x.Label[http.Handler, Middleware]) http.Handler {
+	var fallback http.Handler
+
+	if len(labels) == 0 || len(labels[0].Handler) == 0 {
+		if allow := p.allowedMethods(r); len(allow) > 0 {
+			var fallback http.Handler
+			if p.HandleOPTIONS && r[methodLevel].Is(http.MethodOptions) {
+				fallback = options(allow)
+			} else {
+				fallback = p.methodNotAllowed(allow)
+			}
+			return withAllowedMethods(p.newHandlerFromLabels(r, labels, fallback), allow)
+		}
+		fallback = p.notFound(r)
+	}
+	return p.newHandlerFromLabels(r, labels, fallback)
+}
+
+// notFound returns the most specific subtree fallback matched by the route,
+// or the router-wide one if none.
+func (p *Router) notFound(r x.Route) http.Handler {
+	if labels := p.fallbacks.Match(r); len(labels) > 0 && len(labels[0].Handler) > 0 {
+		return p.newHandlerFromLabels(r, labels, nil)
+	}
+	if p.NotFound != nil {
+		return p.NotFound
+	}
+	return notFound
+}
+
+// allowedMethods probes method labels under the matched schemes,
+// returns the sorted literal methods which are able to handle the rest route.
+func (p *Router) allowedMethods(r x.Route) []string {
+	var allow []string
+	for _, scheme := range p.Router.Match(r[:methodLevel]) {
+		if scheme.Down == nil {
+			continue
+		}
+		for _, method := range scheme.Down.Children() {
+			if method.Down == nil || len(method.Key) != 1 || !method.Key[0].Literal() {
+				continue
+			}
+			if labels := method.Down.Match(r[hostLevel:]); len(labels) > 0 && len(labels[0].Handler) > 0 {
+				allow = append(allow, method.Key[0].String())
+			}
+		}
+	}
+
+	sort.Strings(allow)
+	out := allow[:0]
+	for _, v := range allow {
+		if len(out) == 0 || out[len(out)-1] != v {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (p *Router) methodNotAllowed(allow []string) http.Handler {
+	h := p.MethodNotAllowed
+	if h == nil {
+		h = methodNotAllowed
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// options replies an OPTIONS request with the allowed methods.
+func options(allow []string) http.Handler {
+	allow = append(append([]string(nil), allow...), http.MethodOptions)
+	sort.Strings(allow)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// withAllowedMethods makes the allowed methods available to middleware and handlers.
+func withAllowedMethods(h http.Handler, allow []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), allowKey, allow)))
+	})
 }
 
 // Use associates a route with middleware.
 func (p *Router) Use(c Route, m ...Middleware) (x.CloseFunc, error) {
-	r, err := newRoute(c)
+	r, err := p.newRoute(c)
 	if err != nil {
 		return nil, err
 	}
 
-	m2 := make([]interface{}, 0, len(m))
-	for _, v := range m {
-		m2 = append(m2, v)
+	return p.Router.Use(r, m...)
+}
+
+// UseMeta associates a route with middleware along with metadata.
+func (p *Router) UseMeta(c Route, meta x.Meta, m ...Middleware) (x.CloseFunc, error) {
+	r, err := p.newRoute(c)
+	if err != nil {
+		return nil, err
 	}
 
-	return p.Router.Use(r, m2...)
+	return p.Router.UseMeta(r, &meta, m...)
 }
 
 // UseFunc associates a route with middleware functions.
@@ -84,45 +269,264 @@ func (p *Router) UseFunc(r Route, m ...MiddlewareFunc) (x.CloseFunc, error) {
 }
 
 // Handle associates a route with a `http.Handler`.
-func (p *Router) Handle(c Route, h http.Handler) (x.CloseFunc, error) {
-	r, err := newRoute(c)
+func (p *Router) Handle(c Route, h http.Handler, opts ...HandleOption) (x.CloseFunc, error) {
+	reg, err := p.Register(c, h, opts...)
 	if err != nil {
 		return nil, err
 	}
+	return reg.Close, nil
+}
 
-	return p.Router.Handle(r, h)
+// Register associates a route with a `http.Handler` like `Handle`, but returns a registration
+// by which the handler can be swapped without a routing gap.
+func (p *Router) Register(c Route, h http.Handler, opts ...HandleOption) (*Registration, error) {
+	r, err := p.newRoute(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var o handleOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	reg := &Registration{options: o, inflight: new(x.Inflight)}
+	reg.Registration, err = p.Router.RegisterMeta(r, o.meta, reg.wrap(h))
+	if err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// newRoute creates a route sequence for adding handlers or middleware.
+func (p *Router) newRoute(c Route) (x.Route, error) {
+	r, err := newRoute(c, p.CaseSensitive)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Headers) > 0 {
+		p.headers.Store(true)
+	}
+	if len(c.Query) > 0 {
+		p.query.Store(true)
+	}
+	return r, nil
+}
+
+// HandleNotFound associates a subtree route with a fallback handler, which is called if nothing more
+// specific is matched, e.g. `router.HandleNotFound(Route{Host: "api.example.com"}, h)`.
+// Middleware matched by the request still runs around the fallback.
+func (p *Router) HandleNotFound(c Route, h http.Handler) (x.CloseFunc, error) {
+	r, err := p.newRoute(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.fallbacks.Handle(r, h)
 }
 
 // HandleFunc associates a route with an `http.HandlerFunc`.
-func (p *Router) HandleFunc(c Route, h http.HandlerFunc) (x.CloseFunc, error) {
-	return p.Handle(c, h)
+func (p *Router) HandleFunc(c Route, h http.HandlerFunc, opts ...HandleOption) (x.CloseFunc, error) {
+	return p.Handle(c, h, opts...)
 }
 
 // ServeHTTP implements the `http.Handler` interface.
 func (p *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var r Route
-	r.UseLiteral = true
+	var (
+		h    http.Handler
+		pins *x.Pins
+	)
+
+	source := p.source(req)
+	route, path, err := p.requestRoute(req, source)
+	if err != nil {
+		h = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, err.Error(), 500)
+		})
+	} else {
+		labels := p.Router.Match(route)
+		// counts the request before middleware runs, and matches again if any handler is draining
+		for ok := false; len(labels) > 0 && !ok; {
+			if pins, ok = pin(labels[0].Handler); !ok {
+				labels = p.Router.Match(route)
+			}
+		}
+		defer pins.Release()
+
+		if to := p.redirectPath(route, path, labels); to != "" {
+			h = redirect(to, p.UseEscapedPath)
+		} else {
+			h = p.match(route, labels)
+		}
+	}
+
+	ctx := context.WithValue(req.Context(), RouterContextKey, p)
+	ctx = context.WithValue(ctx, sourceKey, source)
+	ctx = context.WithValue(ctx, pinsKey, pins)
+	h.ServeHTTP(w, req.WithContext(ctx))
+}
 
-	r.Scheme = req.URL.Scheme
-	if r.Scheme == "" {
-		r.Scheme = "http"
+// Lookup returns the registered route which handles the request, the returned route is false
+// if no handler is matched, e.g. the request is replied by 404, 405 or a redirection.
+func (p *Router) Lookup(req *http.Request) (Route, bool) {
+	route, _, err := p.requestRoute(req, p.source(req))
+	if err != nil {
+		return Route{}, false
 	}
 
-	r.Method = req.Method
-	r.Host, _, _ = net.SplitHostPort(req.Host)
-	if r.Host == "" {
-		r.Host = req.Host
+	labels := p.Router.Match(route)
+	if len(labels) == 0 || len(labels[0].Handler) == 0 {
+		return Route{}, false
 	}
+
+	return labelRoute(labels[0]), true
+}
+
+// requestRoute builds the literal route of a request, along with the path used to match.
+func (p *Router) requestRoute(req *http.Request, source SourceValue) (x.Route, string, error) {
+	var r Route
+	r.UseLiteral = true
+
+	r.Scheme = source.Scheme
+	r.Method = req.Method
+	r.Host, r.Port = splitHostPort(source.Host)
 	r.Path = req.URL.Path
+	if p.UseEscapedPath {
+		r.Path = req.URL.EscapedPath()
+	}
 
 	switch strings.ToLower(req.Header.Get("Upgrade")) {
 	case "websocket":
 		if strings.ToLower(req.Header.Get("Connection")) == "upgrade" {
 			// the incoming request should be websocket
-			r.Scheme = "ws"
+			if r.Scheme == "https" {
+				r.Scheme = "wss"
+			} else {
+				r.Scheme = "ws"
+			}
 		}
 	}
 
-	ctx := context.WithValue(req.Context(), RouterContextKey, p)
-	p.Match(r).ServeHTTP(w, req.WithContext(ctx))
+	if r.Port == "" {
+		switch r.Scheme {
+		case "https", "wss":
+			r.Port = "443"
+		default:
+			r.Port = "80"
+		}
+	}
+
+	route, err := newRoute(r, p.CaseSensitive)
+	if err == nil && p.headers.Load() {
+		route[headerLevel], err = newLiteralFieldsKey(req.Header, true)
+	}
+	if err == nil && p.query.Load() {
+		route[queryLevel], err = newLiteralFieldsKey(req.URL.Query(), false)
+	}
+	return route, r.Path, err
+}
+
+// redirectPath returns the alternate path to which the request should be redirected, or empty if none,
+// labels are matched by the route, so that alternate paths are probed only if necessary.
+func (p *Router) redirectPath(route x.Route, path string, labels []*x.Label[http.Handler, Middleware]) string {
+	if p.RedirectFixedPath {
+		if fixed := cleanPath(path); fixed != path && isLocalPath(fixed) {
+			if p.handled(route, fixed) {
+				return fixed
+			}
+			if p.RedirectTrailingSlash && fixed != "/" && p.handled(route, toggleSlash(fixed)) {
+				return toggleSlash(fixed)
+			}
+		}
+	}
+
+	if !p.RedirectTrailingSlash || path == "/" || path == "" || !isLocalPath(path) {
+		return ""
+	}
+
+	if len(labels) > 0 && len(labels[0].Handler) > 0 {
+		if !strings.HasSuffix(path, "/") {
+			return ""
+		}
+		// the trailing empty segment is matched by something other than `**`
+		key := matchedKeys(route, labels[0])[pathLevel]
+		if last := key[len(key)-1]; last.Literal() || last.Wildcards() {
+			return ""
+		}
+	}
+
+	if alt := toggleSlash(path); p.handled(route, alt) {
+		return alt
+	}
+	return ""
+}
+
+// handled returns if a handler exists for the route with the given path.
+func (p *Router) handled(route x.Route, path string) bool {
+	split := lowerLiteralFields
+	if p.CaseSensitive {
+		split = strings.Split
+	}
+	key, err := x.NewStringSliceKey(split(path, "/"))
+	if err != nil {
+		return false
+	}
+
+	r := append(x.Route(nil), route...)
+	r[pathLevel] = key
+	labels := p.Router.Match(r)
+	return len(labels) > 0 && len(labels[0].Handler) > 0
+}
+
+// redirect replies a permanent redirect to the path, query parameters are kept.
+func redirect(path string, escaped bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		u := url.URL{Path: path, RawQuery: r.URL.RawQuery}
+		if escaped {
+			u.Path, u.RawPath = unescapePath(path), path
+		}
+		http.Redirect(w, r, u.RequestURI(), code)
+	})
+}
+
+// cleanPath returns the canonical path, the trailing slash is kept.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	s := path.Clean(p)
+	if p[len(p)-1] == '/' && s != "/" {
+		s += "/"
+	}
+	return s
+}
+
+// isLocalPath returns if the path is safe to redirect to, i.e. not taken as another host by browsers,
+// e.g. `//evil.com` or `/\evil.com`.
+func isLocalPath(path string) bool {
+	return len(path) < 2 || path[1] != '/' && path[1] != '\\'
+}
+
+// toggleSlash adds the trailing slash if absent, otherwise removes it.
+func toggleSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path[:len(path)-1]
+	}
+	return path + "/"
+}
+
+// splitHostPort splits a request host into host and port, brackets of IPv6 literals are removed.
+func splitHostPort(hostport string) (host, port string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]"), ""
+	}
+	return host, port
 }
@@ -1,9 +1,16 @@
 package http
 
 import (
+	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -89,6 +96,9 @@ func TestRouter_ServeHTTP(t *testing.T) {
 		{Path: "/v[2-3]"},
 		{Path: "/v4/**/x"},
 		{Path: "/v4/*/**/x"},
+		{Path: "/v5/{re:^[0-9]+$}"},
+		{Path: "/v5/*"},
+		{Host: "{re:^shard[0-9]+$}.example.com", Path: "/v6"},
 	}
 
 	router := NewRouter()
@@ -232,6 +242,46 @@ func TestRouter_ServeHTTP(t *testing.T) {
 				"* *://**/**",
 			},
 		},
+		{
+			Route{Path: "/v5/10"},
+			[]string{
+				"* *://**/v5/{re:^[0-9]+$}",
+			},
+			[]string{
+				"* *://**/v5/{re:^[0-9]+$}",
+				"* *://**/v5/*",
+				"* *://**/**",
+			},
+		},
+		{
+			Route{Path: "/v5/x"},
+			[]string{
+				"* *://**/v5/*",
+			},
+			[]string{
+				"* *://**/v5/*",
+				"* *://**/**",
+			},
+		},
+		{
+			Route{Host: "shard1.example.com", Path: "/v6"},
+			[]string{
+				"* *://{re:^shard[0-9]+$}.example.com/v6",
+			},
+			[]string{
+				"* *://{re:^shard[0-9]+$}.example.com/v6",
+				"* *://**/**",
+			},
+		},
+		{
+			Route{Host: "shard.example.com", Path: "/v6"},
+			[]string{
+				"* *://**/**",
+			},
+			[]string{
+				"* *://**/**",
+			},
+		},
 	}
 
 	for i, c := range cases {
@@ -269,6 +319,1728 @@ func TestRouter_ServeHTTP(t *testing.T) {
 	}
 }
 
+func TestVars(t *testing.T) {
+	routes := []Route{
+		{Path: "/users/{id}"},
+		{Path: "/users/:id/posts/{post:re:^[0-9]+$}"},
+		{Host: "{tenant}.example.com", Path: "/files/**"},
+		{Path: "/orders/{id:int}"},
+		{Path: "/orders/*"},
+	}
+
+	router := NewRouter()
+	for _, route := range routes {
+		_, err := router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			vars := Vars(r)
+			w.Header().Set("Host", strings.Join(vars.Host, " "))
+			w.Header().Set("Path", strings.Join(vars.Path, " "))
+			for k, v := range vars.Params {
+				w.Header().Set("Param-"+k, v)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		host, path string
+		y          map[string]string
+	}{
+		{
+			"localhost", "/users/1",
+			map[string]string{
+				"Host":     "** localhost",
+				"Path":     "/users/{id} 1",
+				"Param-Id": "1",
+			},
+		},
+		{
+			"localhost", "/users/1/posts/2",
+			map[string]string{
+				"Host":       "** localhost",
+				"Path":       "/users/{id}/posts/{post:re:^[0-9]+$} 1 2",
+				"Param-Id":   "1",
+				"Param-Post": "2",
+			},
+		},
+		{
+			"localhost", "/users/1/posts/x",
+			map[string]string{},
+		},
+		{
+			"acme.example.com", "/files/a/b",
+			map[string]string{
+				"Host":         "{tenant}.example.com acme",
+				"Path":         "/files/** a/b",
+				"Param-Tenant": "acme",
+			},
+		},
+		{
+			"localhost", "/orders/-1",
+			map[string]string{
+				"Host":     "** localhost",
+				"Path":     "/orders/{id:int} -1",
+				"Param-Id": "-1",
+			},
+		},
+		{
+			"localhost", "/orders/x",
+			map[string]string{
+				"Host": "** localhost",
+				"Path": "/orders/* x",
+			},
+		},
+	}
+
+	for i, c := range cases {
+		request, err := http.NewRequest("GET", c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Host = c.host
+
+		w := newHeaderWriter()
+		router.ServeHTTP(w, request)
+		y := make(map[string]string)
+		for k := range w.Header() {
+			y[k] = w.Header().Get(k)
+		}
+		delete(y, "Content-Type")
+		delete(y, "X-Content-Type-Options")
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestRouter_HeadersAndQuery(t *testing.T) {
+	routes := []Route{
+		{Path: "/api/*"},
+		{Path: "/api/*", Headers: map[string]string{"X-Api-Version": "2*"}},
+		{Path: "/api/*", Headers: map[string]string{"X-Api-Version": "{version:re:^1}"}},
+		{Path: "/api/*", Query: map[string]string{"format": "json"}},
+		{Path: "/api/*", Headers: map[string]string{"X-Tenant": "{tenant}"}, Query: map[string]string{"format": "{format}"}},
+	}
+
+	router := NewRouter()
+	for _, route := range routes {
+		_, err := router.HandleFunc(route, func(s string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				vars := Vars(r)
+				w.Header().Set("Y", s)
+				w.Header().Set("Header", strings.Join(vars.Header, " "))
+				w.Header().Set("Query", strings.Join(vars.Query, " "))
+				for k, v := range vars.Params {
+					w.Header().Set("Param-"+k, v)
+				}
+			}
+		}(route.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := router.Routes()
+	if len(out) != len(routes) {
+		t.Fatalf("expected %d routes, got %d", len(routes), len(out))
+	}
+	for _, r := range out {
+		if r.Headers != nil && r.Headers["x-api-version"] == "" && r.Headers["x-tenant"] == "" {
+			t.Errorf("bad route headers: %v", r.Headers)
+		}
+	}
+
+	cases := []struct {
+		url    string
+		header map[string]string
+		y      map[string]string
+	}{
+		{
+			"/api/x", nil,
+			map[string]string{
+				"Y": "* *://**/api/*",
+			},
+		},
+		{
+			"/api/x", map[string]string{"X-Api-Version": "2.1"},
+			map[string]string{
+				"Y":      "* *://**/api/* x-api-version:2*",
+				"Header": "x-api-version:2* 2.1",
+			},
+		},
+		{
+			"/api/x", map[string]string{"X-Api-Version": "1.0"},
+			map[string]string{
+				"Y":             "* *://**/api/* x-api-version:{version:re:^1}",
+				"Header":        "x-api-version:{version:re:^1} 1.0",
+				"Param-Version": "1.0",
+			},
+		},
+		{
+			"/api/x?format=json", nil,
+			map[string]string{
+				"Y":     "* *://**/api/*?format=json",
+				"Query": "format=json",
+			},
+		},
+		{
+			"/api/x?format=json", map[string]string{"X-Api-Version": "2"},
+			map[string]string{
+				"Y":      "* *://**/api/* x-api-version:2*",
+				"Header": "x-api-version:2* 2",
+			},
+		},
+		{
+			"/api/x?format=xml", map[string]string{"X-Tenant": "acme"},
+			map[string]string{
+				"Y":            "* *://**/api/*?format={format} x-tenant:{tenant}",
+				"Header":       "x-tenant:{tenant} acme",
+				"Query":        "format={format} xml",
+				"Param-Tenant": "acme",
+				"Param-Format": "xml",
+			},
+		},
+		{
+			"/api/x?format=xml", nil,
+			map[string]string{
+				"Y": "* *://**/api/*",
+			},
+		},
+	}
+
+	for i, c := range cases {
+		request, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range c.header {
+			request.Header.Set(k, v)
+		}
+
+		w := newHeaderWriter()
+		router.ServeHTTP(w, request)
+		y := make(map[string]string)
+		for k := range w.Header() {
+			if v := w.Header().Get(k); v != "" {
+				y[k] = v
+			}
+		}
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestRouter_Port(t *testing.T) {
+	routes := []Route{
+		{Host: "example.com"},
+		{Host: "example.com", Port: "8080"},
+		{Host: "example.com", Port: "9090"},
+		{Host: "int.example.com", Port: "{port:int}"},
+		{Host: "[::1]", Port: "80*"},
+	}
+
+	router := NewRouter()
+	for _, route := range routes {
+		_, err := router.HandleFunc(route, func(s string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				vars := Vars(r)
+				w.Header().Set("Y", s)
+				w.Header().Set("Port", strings.Join(vars.Port, " "))
+				w.Header().Set("Param-Port", vars.Get("port"))
+			}
+		}(route.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		scheme, host string
+		y            map[string]string
+	}{
+		{
+			"http", "example.com:8080",
+			map[string]string{
+				"Y":    "* *://example.com:8080/**",
+				"Port": "8080",
+			},
+		},
+		{
+			"http", "example.com:9090",
+			map[string]string{
+				"Y":    "* *://example.com:9090/**",
+				"Port": "9090",
+			},
+		},
+		{
+			"http", "example.com",
+			map[string]string{
+				"Y":    "* *://example.com/**",
+				"Port": "* 80",
+			},
+		},
+		{
+			"https", "int.example.com",
+			map[string]string{
+				"Y":          "* *://int.example.com:{port:int}/**",
+				"Port":       "{port:int} 443",
+				"Param-Port": "443",
+			},
+		},
+		{
+			"http", "[::1]:8000",
+			map[string]string{
+				"Y":    "* *://[::1]:80*/**",
+				"Port": "80* 8000",
+			},
+		},
+		{
+			"http", "[::1]",
+			map[string]string{
+				"Y":    "* *://[::1]:80*/**",
+				"Port": "80* 80",
+			},
+		},
+		{
+			"http", "[::1]:9000",
+			map[string]string{},
+		},
+	}
+
+	for i, c := range cases {
+		request, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.URL.Scheme = c.scheme
+		request.Host = c.host
+
+		w := newHeaderWriter()
+		router.ServeHTTP(w, request)
+		y := make(map[string]string)
+		for k := range w.Header() {
+			if v := w.Header().Get(k); v != "" {
+				y[k] = v
+			}
+		}
+		delete(y, "Content-Type")
+		delete(y, "X-Content-Type-Options")
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestRouter_CaseSensitive(t *testing.T) {
+	routes := []Route{
+		{Path: "/Files/{key}"},
+		{Path: "/files/*"},
+		{Path: "/[A-Z]*"},
+	}
+
+	newRouter := func(caseSensitive bool) *Router {
+		router := NewRouter()
+		router.CaseSensitive = caseSensitive
+		for _, route := range routes {
+			_, err := router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+				vars := Vars(r)
+				w.Header().Set("Path", strings.Join(vars.Path, " "))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return router
+	}
+
+	cases := []struct {
+		caseSensitive bool
+		path, y       string
+	}{
+		{true, "/Files/ABC", "/Files/{key} ABC"},
+		{true, "/files/ABC", "/files/* ABC"},
+		{true, "/FILES/ABC", ""},
+		{true, "/Upper", "/[A-Z]* Upper"},
+		{true, "/lower", ""},
+		{false, "/Files/ABC", "/files/* abc"},
+		{false, "/FILES/ABC", "/files/* abc"},
+		{false, "/Upper", "/[a-z]* upper"},
+	}
+
+	routers := map[bool]*Router{
+		true:  newRouter(true),
+		false: newRouter(false),
+	}
+	for i, c := range cases {
+		request, err := http.NewRequest("GET", c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		routers[c.caseSensitive].ServeHTTP(w, request)
+		if y := w.Header().Get("Path"); y != c.y {
+			t.Errorf("bad case %d: expected %q, got %q", i+1, c.y, y)
+		}
+	}
+
+	if r := routers[true].Routes(); len(r) != len(routes) || r[0].Path == r[1].Path {
+		t.Errorf("bad routes: %v", r)
+	}
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	routes := []Route{
+		{Method: "GET", Path: "/items"},
+		{Method: "POST", Path: "/items"},
+		{Method: "GET", Path: "/items/*"},
+		{Method: "DELETE", Path: "/items/*"},
+		{Method: "P*", Path: "/items/*"},
+		{Scheme: "https", Method: "PUT", Path: "/items"},
+	}
+
+	router := NewRouter()
+	for _, route := range routes {
+		_, err := router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		method, path string
+		code         int
+		allow        string
+	}{
+		{"GET", "/items", 200, ""},
+		{"PUT", "/items", 405, "GET, POST"},
+		{"put", "/items", 405, "GET, POST"},
+		{"PATCH", "/items/1", 200, ""},
+		{"POST", "/items/1", 200, ""},
+		{"OPTIONS", "/items/1", 405, "DELETE, GET"},
+		{"PUT", "/others", 404, ""},
+	}
+
+	for i, c := range cases {
+		request, err := http.NewRequest(c.method, c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		if w.Code != c.code {
+			t.Errorf("bad case %d: expected code %d, got %d", i+1, c.code, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("bad case %d: expected Allow %q, got %q", i+1, c.allow, allow)
+		}
+	}
+
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	request, err := http.NewRequest("PUT", "/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	if w.Code != http.StatusTeapot || w.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("bad custom handler: got %d with Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestRouter_HandleOPTIONS(t *testing.T) {
+	router := NewRouter()
+	router.HandleOPTIONS = true
+
+	for _, route := range []Route{
+		{Method: "GET", Path: "/items"},
+		{Method: "POST", Path: "/items"},
+	} {
+		_, err := router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := router.Use(Route{Path: "/items"}, CORS(CORSPolicy{
+		AllowOrigins: []string{"https://a.example.com"},
+		MaxAge:       time.Minute,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method, path string
+		header       map[string]string
+		code         int
+		y            map[string]string
+	}{
+		{
+			"OPTIONS", "/items", nil, 204,
+			map[string]string{
+				"Allow": "GET, OPTIONS, POST",
+			},
+		},
+		{
+			"OPTIONS", "/items",
+			map[string]string{
+				"Origin":                        "https://a.example.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			204,
+			map[string]string{
+				"Vary":                         "Origin",
+				"Access-Control-Allow-Origin":  "https://a.example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Max-Age":       "60",
+			},
+		},
+		{
+			"GET", "/items",
+			map[string]string{
+				"Origin": "https://a.example.com",
+			},
+			200,
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "https://a.example.com",
+			},
+		},
+		{
+			"GET", "/items",
+			map[string]string{
+				"Origin": "https://b.example.com",
+			},
+			200,
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"OPTIONS", "/others", nil, 404,
+			map[string]string{},
+		},
+	}
+
+	for i, c := range cases {
+		request, err := http.NewRequest(c.method, c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range c.header {
+			request.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		if w.Code != c.code {
+			t.Errorf("bad case %d: expected code %d, got %d", i+1, c.code, w.Code)
+		}
+		y := make(map[string]string)
+		for k := range w.Header() {
+			y[k] = w.Header().Get(k)
+		}
+		delete(y, "Content-Type")
+		delete(y, "X-Content-Type-Options")
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestRouter_TrustedProxies(t *testing.T) {
+	_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := NewRouter()
+	router.TrustedProxies = []*net.IPNet{trusted}
+	for _, route := range []Route{
+		{Scheme: "http", Host: "example.com"},
+		{Scheme: "https", Host: "example.com"},
+		{Scheme: "https", Host: "public.example.com"},
+		{Scheme: "ws", Host: "example.com"},
+		{Scheme: "wss", Host: "example.com"},
+	} {
+		_, err := router.HandleFunc(route, func(s string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				source := Source(r)
+				w.Header().Set("Y", s)
+				w.Header().Set("Scheme", source.Scheme+" "+source.SchemeFrom)
+				w.Header().Set("Host", source.Host+" "+source.HostFrom)
+			}
+		}(route.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		remoteAddr string
+		tls        bool
+		header     map[string]string
+		y          map[string]string
+	}{
+		{
+			"192.0.2.1:1234", false, nil,
+			map[string]string{
+				"Y":      "* http://example.com/**",
+				"Scheme": "http default",
+				"Host":   "example.com host",
+			},
+		},
+		{
+			"192.0.2.1:1234", true, nil,
+			map[string]string{
+				"Y":      "* https://example.com/**",
+				"Scheme": "https tls",
+				"Host":   "example.com host",
+			},
+		},
+		{
+			"192.0.2.1:1234", false,
+			map[string]string{
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "public.example.com",
+			},
+			map[string]string{
+				"Y":      "* http://example.com/**",
+				"Scheme": "http default",
+				"Host":   "example.com host",
+			},
+		},
+		{
+			"10.0.0.1:1234", false,
+			map[string]string{
+				"X-Forwarded-Proto": "http, https",
+				"X-Forwarded-Host":  "public.example.com",
+			},
+			map[string]string{
+				"Y":      "* https://public.example.com/**",
+				"Scheme": "https x-forwarded",
+				"Host":   "public.example.com x-forwarded",
+			},
+		},
+		{
+			"10.0.0.1:1234", false,
+			map[string]string{
+				"X-Forwarded-Proto": "http",
+				"Forwarded":         `for=192.0.2.60;proto=https;host="public.example.com"`,
+			},
+			map[string]string{
+				"Y":      "* https://public.example.com/**",
+				"Scheme": "https forwarded",
+				"Host":   "public.example.com forwarded",
+			},
+		},
+		{
+			"192.0.2.1:1234", false,
+			map[string]string{"Upgrade": "websocket", "Connection": "Upgrade"},
+			map[string]string{
+				"Y":      "* ws://example.com/**",
+				"Scheme": "http default",
+				"Host":   "example.com host",
+			},
+		},
+		{
+			"192.0.2.1:1234", true,
+			map[string]string{"Upgrade": "websocket", "Connection": "Upgrade"},
+			map[string]string{
+				"Y":      "* wss://example.com/**",
+				"Scheme": "https tls",
+				"Host":   "example.com host",
+			},
+		},
+	}
+
+	for i, c := range cases {
+		request, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Host = "example.com"
+		request.RemoteAddr = c.remoteAddr
+		if c.tls {
+			request.TLS = new(tls.ConnectionState)
+		}
+		for k, v := range c.header {
+			request.Header.Set(k, v)
+		}
+
+		w := newHeaderWriter()
+		router.ServeHTTP(w, request)
+		y := make(map[string]string)
+		for k := range w.Header() {
+			y[k] = w.Header().Get(k)
+		}
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestRouter_Redirect(t *testing.T) {
+	router := NewRouter()
+	router.RedirectTrailingSlash = true
+	router.RedirectFixedPath = true
+
+	for _, route := range []Route{
+		{Path: "/v1"},
+		{Path: "/v1/*"},
+		{Path: "/v2/"},
+		{Path: "/v3/**"},
+		{Path: "/a/b"},
+	} {
+		_, err := router.HandleFunc(route, func(s string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Y", s)
+			}
+		}(route.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		method, path, query string
+		code                int
+		y                   map[string]string
+	}{
+		{"GET", "/v1", "", 200, map[string]string{"Y": "* *://**/v1"}},
+		{"GET", "/v1/", "", 301, map[string]string{"Location": "/v1"}},
+		{"POST", "/v1/", "x=1", 308, map[string]string{"Location": "/v1?x=1"}},
+		{"GET", "/v1/a", "", 200, map[string]string{"Y": "* *://**/v1/*"}},
+		{"GET", "/v2", "", 301, map[string]string{"Location": "/v2/"}},
+		{"GET", "/v2/", "", 200, map[string]string{"Y": "* *://**/v2/"}},
+		{"GET", "/v3/", "", 200, map[string]string{"Y": "* *://**/v3/**"}},
+		{"GET", "/a//b", "", 301, map[string]string{"Location": "/a/b"}},
+		{"GET", "/a/c/../b/", "", 301, map[string]string{"Location": "/a/b"}},
+		{"GET", "/a/./c", "", 404, map[string]string{}},
+	}
+
+	for i, c := range cases {
+		request, err := http.NewRequest(c.method, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.URL.Path = c.path
+		request.URL.RawQuery = c.query
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		if w.Code != c.code {
+			t.Errorf("bad case %d: expected code %d, got %d", i+1, c.code, w.Code)
+		}
+		y := make(map[string]string)
+		for _, k := range []string{"Y", "Location"} {
+			if v := w.Header().Get(k); v != "" {
+				y[k] = v
+			}
+		}
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestRouter_OpenRedirect(t *testing.T) {
+	for _, fixed := range []bool{false, true} {
+		router := NewRouter()
+		router.RedirectTrailingSlash = true
+		router.RedirectFixedPath = fixed
+		if _, err := router.HandleFunc(Route{Path: "/{user}/{repo}"}, func(http.ResponseWriter, *http.Request) {}); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, path := range []string{"//evil.com/", "/\\evil.com/", "/\\evil.com/./x", "//evil.com/./"} {
+			request, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.URL.Path = path
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+			if location := w.Header().Get("Location"); strings.HasPrefix(location, "//") || strings.HasPrefix(location, "/\\") {
+				t.Errorf("bad case %d: unexpected redirect to %q with fixed path %v", i+1, location, fixed)
+			}
+		}
+	}
+}
+
+func TestRouter_UseEscapedPath(t *testing.T) {
+	for _, escaped := range []bool{false, true} {
+		router := NewRouter()
+		router.CaseSensitive = true
+		router.UseEscapedPath = escaped
+
+		_, err := router.HandleFunc(Route{Path: "/objects/{bucket}/{key}"}, func(w http.ResponseWriter, r *http.Request) {
+			vars := Vars(r)
+			w.Header().Set("Path", strings.Join(vars.Path, " "))
+			w.Header().Set("Raw-Path", strings.Join(vars.RawPath, " "))
+			w.Header().Set("Key", vars.Get("key"))
+			w.Header().Set("Raw-Key", vars.GetRaw("key"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request, err := http.NewRequest("GET", "/objects/b1/dir%2FA%20b.txt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		if !escaped {
+			if w.Code != 404 {
+				t.Errorf("expected 404 without escaped path, got %d", w.Code)
+			}
+			continue
+		}
+
+		y := map[string]string{
+			"Path":     "/objects/{bucket}/{key} b1 dir/A b.txt",
+			"Raw-Path": "/objects/{bucket}/{key} b1 dir%2FA%20b.txt",
+			"Key":      "dir/A b.txt",
+			"Raw-Key":  "dir%2FA%20b.txt",
+		}
+		for k, v := range y {
+			if s := w.Header().Get(k); s != v {
+				t.Errorf("bad %s: expected %q, got %q", k, v, s)
+			}
+		}
+	}
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	router := NewRouter()
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Y", "router")
+		w.WriteHeader(404)
+	})
+
+	_, err := router.HandleFunc(Route{Host: "api.example.com", Path: "/v1/users"}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Y", "users")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = router.UseFunc(Route{Host: "api.example.com"}, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("M", "api")
+			h.ServeHTTP(w, r)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, route := range []Route{
+		{Host: "api.example.com"},
+		{Host: "api.example.com", Path: "/v1/**"},
+	} {
+		_, err = router.HandleNotFound(route, func(s string) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Y", s)
+				w.WriteHeader(404)
+			})
+		}(route.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		host, path string
+		code       int
+		y          map[string]string
+	}{
+		{"api.example.com", "/v1/users", 200, map[string]string{"Y": "users", "M": "api"}},
+		{"api.example.com", "/v1/groups", 404, map[string]string{"Y": "* *://api.example.com/v1/**", "M": "api"}},
+		{"api.example.com", "/v2", 404, map[string]string{"Y": "* *://api.example.com/**", "M": "api"}},
+		{"www.example.com", "/v1/users", 404, map[string]string{"Y": "router"}},
+	}
+
+	for i, c := range cases {
+		request, err := http.NewRequest("GET", c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Host = c.host
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		if w.Code != c.code {
+			t.Errorf("bad case %d: expected code %d, got %d", i+1, c.code, w.Code)
+		}
+		y := make(map[string]string)
+		for _, k := range []string{"Y", "M"} {
+			if v := w.Header().Get(k); v != "" {
+				y[k] = v
+			}
+		}
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestRouter_ChainPolicy(t *testing.T) {
+	write := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Y", s)
+			w.Write([]byte(s))
+		}
+	}
+	skip := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Y", s)
+		}
+	}
+	next := func(s string, call bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Y", s)
+			if call {
+				Next(w, r)
+			}
+		}
+	}
+
+	cases := []struct {
+		router   ChainPolicy
+		route    ChainPolicy
+		handlers []http.HandlerFunc
+		y        []string
+		body     string
+	}{
+		{ChainDefault, ChainDefault, []http.HandlerFunc{write("a"), write("b")}, []string{"a"}, "ab"},
+		{ChainFirstWrite, ChainDefault, []http.HandlerFunc{skip("a"), write("b"), write("c")}, []string{"a", "b"}, "b"},
+		{ChainAll, ChainFirstWrite, []http.HandlerFunc{write("a"), write("b")}, []string{"a"}, "a"},
+		{ChainCommitted, ChainDefault, []http.HandlerFunc{skip("a"), write("b"), skip("c")}, []string{"a", "b"}, "b"},
+		{ChainNext, ChainDefault, []http.HandlerFunc{next("a", true), next("b", false), write("c")}, []string{"a", "b"}, ""},
+		{ChainDefault, ChainNext, []http.HandlerFunc{next("a", true), next("b", true), write("c")}, []string{"a", "b", "c"}, "c"},
+	}
+
+	for i, c := range cases {
+		router := NewRouter()
+		router.ChainPolicy = c.router
+		for _, h := range c.handlers {
+			if _, err := router.HandleFunc(Route{}, h, WithChain(c.route)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		request, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		if y := w.Result().Header["Y"]; !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected headers %v, got %v", i+1, c.y, y)
+		}
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("bad case %d: expected body %q, got %q", i+1, c.body, body)
+		}
+	}
+}
+
+func TestRouter_Balancer(t *testing.T) {
+	blue, green := x.NewWeight("blue", 3), x.NewWeight("green", 1)
+
+	serve := func(router *Router, remoteAddr string) (string, int) {
+		request, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Body.String(), w.Code
+	}
+
+	cases := []struct {
+		balancer x.Balancer
+		blue     int
+		green    int
+		y        []string
+	}{
+		{new(x.RoundRobin), 3, 1, []string{"blue", "green", "blue", "green"}},
+		{new(x.WeightedRoundRobin), 3, 1, []string{"blue", "blue", "blue", "green"}},
+		{new(x.WeightedRoundRobin), 0, 1, []string{"green", "green", "green", "green"}},
+		{x.Random{}, 1, 0, []string{"blue", "blue", "blue", "blue"}},
+		{x.ConsistentHash{}, 0, 1, []string{"green", "green", "green", "green"}},
+	}
+
+	for i, c := range cases {
+		router := NewRouter()
+		router.Balancer = c.balancer
+		for _, w := range []*x.Weight{blue, green} {
+			_, err := router.HandleFunc(Route{}, func(s string) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(s))
+				}
+			}(w.Name()), WithWeight(w))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		blue.Store(c.blue)
+		green.Store(c.green)
+		var y []string
+		for range c.y {
+			s, _ := serve(router, "192.0.2.1:1234")
+			y = append(y, s)
+		}
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+
+	router := NewRouter()
+	router.Balancer = x.ConsistentHash{}
+	for _, w := range []*x.Weight{blue, green} {
+		if _, err := router.HandleFunc(Route{}, func(s string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(s))
+			}
+		}(w.Name()), WithWeight(w)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blue.Store(1)
+	green.Store(1)
+	selected := make(map[string]string)
+	for i := 0; i < 32; i++ {
+		addr := "192.0.2." + strconv.Itoa(i) + ":1234"
+		selected[addr], _ = serve(router, addr)
+		if s, _ := serve(router, addr); s != selected[addr] {
+			t.Errorf("expected %s for %s, got %s", selected[addr], addr, s)
+		}
+	}
+	counts := make(map[string]int)
+	for _, s := range selected {
+		counts[s]++
+	}
+	if counts["blue"] == 0 || counts["green"] == 0 {
+		t.Errorf("expected both handlers selected, got %v", counts)
+	}
+
+	blue.Store(0)
+	green.Store(0)
+	if _, code := serve(router, "192.0.2.1:1234"); code != 503 {
+		t.Errorf("expected 503 if all drained, got %d", code)
+	}
+}
+
+func TestRouter_Register(t *testing.T) {
+	router := NewRouter()
+	reg, err := router.Register(Route{Path: "/v1"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 100; i++ {
+			s := strconv.Itoa(i)
+			if err := reg.Swap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(s))
+			})); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		request, err := http.NewRequest("GET", "/v1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		if w.Code != 200 || w.Body.Len() == 0 {
+			t.Fatalf("expected a handler during swapping, got %d %q", w.Code, w.Body.String())
+		}
+	}
+
+	request, err := http.NewRequest("GET", "/v1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	if s := w.Body.String(); s != "100" {
+		t.Errorf("expected the last swapped handler, got %q", s)
+	}
+
+	if err := reg.SwapMeta(&x.Meta{Name: "v1"}, http.NotFoundHandler()); err != nil {
+		t.Fatal(err)
+	}
+	if routes := router.Routes(); len(routes) != 1 || len(routes[0].Meta) != 1 || routes[0].Meta[0].Name != "v1" {
+		t.Errorf("expected metadata swapped, got %v", routes)
+	}
+	if err := reg.SwapMeta(nil, http.NotFoundHandler()); err != nil {
+		t.Fatal(err)
+	}
+	if routes := router.Routes(); len(routes) != 1 || len(routes[0].Meta) != 0 {
+		t.Errorf("expected metadata removed, got %v", routes)
+	}
+
+	reg.Close()
+	reg.Close()
+	if err := reg.Swap(http.NotFoundHandler()); err != x.ErrClosedRegistration {
+		t.Errorf("expected %v, got %v", x.ErrClosedRegistration, err)
+	}
+	if n := len(router.Routes()); n != 0 {
+		t.Errorf("expected no routes after closed, got %d", n)
+	}
+}
+
+func TestRegistration_CloseContext(t *testing.T) {
+	router := NewRouter()
+	started, release := make(chan struct{}), make(chan struct{})
+	reg, err := router.Register(Route{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		request, _ := http.NewRequest("GET", "/", nil)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}()
+	<-started
+	if n := reg.Inflight(); n != 1 {
+		t.Fatalf("expected 1 in-flight request, got %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := reg.CloseContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	close(release)
+	if err := reg.CloseContext(context.Background()); err != nil {
+		t.Errorf("expected drained, got %v", err)
+	}
+	<-served
+	if n := reg.Inflight(); n != 0 {
+		t.Errorf("expected no in-flight request, got %d", n)
+	}
+}
+
+func TestRegistration_CloseContextMiddleware(t *testing.T) {
+	router := NewRouter()
+	entered, release := make(chan struct{}), make(chan struct{})
+	_, err := router.UseFunc(Route{}, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+			h.ServeHTTP(w, r)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var served atomic.Bool
+	reg, err := router.Register(Route{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Store(true)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		request, _ := http.NewRequest("GET", "/", nil)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}()
+	<-entered
+	if n := reg.Inflight(); n != 1 {
+		t.Fatalf("expected 1 in-flight request, got %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := reg.CloseContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	close(release)
+	if err := reg.CloseContext(context.Background()); err != nil {
+		t.Errorf("expected drained, got %v", err)
+	}
+	if !served.Load() {
+		t.Error("expected the handler is served before drained")
+	}
+	<-done
+	if n := reg.Inflight(); n != 0 {
+		t.Errorf("expected no in-flight request, got %d", n)
+	}
+
+	// only the handler selected from a pool is counted
+	router = NewRouter()
+	router.Balancer = new(x.RoundRobin)
+	started, release := make(chan struct{}), make(chan struct{})
+	var regs []*Registration
+	for i := 0; i < 2; i++ {
+		reg, err := router.Register(Route{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		regs = append(regs, reg)
+	}
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		request, _ := http.NewRequest("GET", "/", nil)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}()
+	<-started
+	if a, b := regs[0].Inflight(), regs[1].Inflight(); a+b != 1 {
+		t.Errorf("expected 1 in-flight request, got %d and %d", a, b)
+	}
+	close(release)
+	<-done
+}
+
+func TestRouter_Meta(t *testing.T) {
+	router := NewRouter()
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	closer, err := router.HandleFunc(Route{Path: "/users"}, handler, WithMeta(x.Meta{
+		Name:  "users.list",
+		Tags:  []string{"users", "public"},
+		Owner: "team-a",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = router.UseMeta(Route{Path: "/users"}, x.Meta{Name: "users.auth", Tags: []string{"auth"}}, MiddlewareFunc(func(h http.Handler) http.Handler {
+		return h
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = router.HandleFunc(Route{Path: "/groups"}, handler, WithMeta(x.Meta{Name: "groups.list", Tags: []string{"public"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = router.HandleFunc(Route{Path: "/health"}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(routes []Route) []string {
+		var out []string
+		for _, r := range routes {
+			for _, m := range r.Meta {
+				if m.Created.IsZero() {
+					t.Errorf("expected the creation time of %s", m.Name)
+				}
+				out = append(out, m.Name)
+			}
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	if y := names(router.Routes()); !reflect.DeepEqual(y, []string{"groups.list", "users.auth", "users.list"}) {
+		t.Errorf("bad routes: got %v", y)
+	}
+	if y := names(router.RoutesByTag("public")); !reflect.DeepEqual(y, []string{"groups.list", "users.auth", "users.list"}) {
+		t.Errorf("bad public routes: got %v", y)
+	}
+	if y := names(router.RoutesByTag("auth")); !reflect.DeepEqual(y, []string{"users.auth", "users.list"}) {
+		t.Errorf("bad auth routes: got %v", y)
+	}
+	if routes := router.RoutesByTag("users"); len(routes) != 1 || routes[0].Meta[0].Owner != "team-a" {
+		t.Errorf("bad users routes: got %v", routes)
+	}
+
+	closer()
+	if y := names(router.RoutesByTag("public")); !reflect.DeepEqual(y, []string{"groups.list"}) {
+		t.Errorf("bad public routes after closed: got %v", y)
+	}
+}
+
+func TestRouter_URL(t *testing.T) {
+	router := NewRouter()
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	for _, c := range []struct {
+		name  string
+		route Route
+	}{
+		{"user", Route{Path: "/users/{id:int}"}},
+		{"file", Route{Path: "/files/**"}},
+		{"tenant", Route{Scheme: "https", Host: "*.example.com", Port: "8443", Path: "/v1/*/items"}},
+		{"local", Route{Host: "[::1]", Path: "/"}},
+		{"any", Route{}},
+	} {
+		if _, err := router.HandleFunc(c.route, handler, WithMeta(x.Meta{Name: c.name})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		params []string
+		y      string
+		err    bool
+	}{
+		{"user", []string{"42"}, "/users/42", false},
+		{"user", []string{"abc"}, "", true},
+		{"user", nil, "", true},
+		{"user", []string{"42", "43"}, "", true},
+		{"file", []string{"a/b c.txt"}, "/files/a/b%20c.txt", false},
+		{"tenant", []string{"acme", "orders"}, "https://acme.example.com:8443/v1/orders/items", false},
+		{"tenant", []string{"a.b", "orders"}, "", true},
+		{"local", nil, "//[::1]/", false},
+		{"any", []string{"a/b"}, "/a/b", false},
+		{"unknown", nil, "", true},
+	}
+
+	for i, c := range cases {
+		u, err := router.URL(c.name, c.params...)
+		if c.err {
+			if err == nil {
+				t.Errorf("bad case %d: expected an error, got %v", i+1, u)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("bad case %d: %v", i+1, err)
+			continue
+		}
+		if y := u.String(); y != c.y {
+			t.Errorf("bad case %d: expected %q, got %q", i+1, c.y, y)
+		}
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	routes := []Route{
+		{},
+		{Method: "GET", Scheme: "https", Host: "*.example.com", Path: "/api/**"},
+		{Method: "post", Path: "/users/{id:int}"},
+		{Host: "{tenant:re:^[a-z]+$}.example.com", Port: "{port:int}", Path: "/{re:^v[0-9]+$}/items"},
+		{Host: "::1", Path: "/"},
+		{Host: "[::1]", Port: "80*", Path: "/a/b/"},
+		{Host: ":tenant.example.com", Port: ":port"},
+		{Path: "/search", Query: map[string]string{"q": "*", "format": "json"}},
+		{Path: "/v2", Headers: map[string]string{"X-Api-Version": "2*", "Accept": "{re:json$}"}},
+		{Path: "/v3", Headers: map[string]string{"Accept": "text/html, application/json", "X-Quote": `say "hi"`}},
+		{Path: "/s", Query: map[string]string{"q": "a b", "x": "a&b=c", "a b": "", "{": "}"}},
+		{Path: "/Users/{ID}"},
+		{Path: "/v?/x"},
+		{Host: "api?.example.com", Path: "/a b/\"c\""},
+		{Host: "api?.example.com", Port: "80*", Path: "/x?y", Query: map[string]string{"q": "1"}},
+		{Path: "/a b", Headers: map[string]string{"Accept": "*"}},
+	}
+
+	for i, r := range routes {
+		s := r.String()
+		p, err := ParseRoute(s)
+		if err != nil {
+			t.Errorf("bad case %d: %v", i+1, err)
+			continue
+		}
+		if y := p.String(); y != s {
+			t.Errorf("bad case %d: expected %q, got %q", i+1, s, y)
+		}
+
+		r1, err := newRoute(r, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r2, err := newRoute(p, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for level := range r1 {
+			if !r1[level].Equal(r2[level]) {
+				t.Errorf("bad case %d: level %d expected %v, got %v", i+1, level, r1[level], r2[level])
+			}
+		}
+	}
+
+	errors := []struct {
+		s      string
+		offset int
+		part   string
+	}{
+		{"GET", 3, ""},
+		{"GET example.com/", 4, "example.com/"},
+		{"GET http://example.com", 11, "example.com"},
+		{"GET http:///a", 11, ""},
+		{"GET http://example.com/users/{id:foo}", 23, "users/{id:foo}"},
+		{"GET http://example.com:/", 23, ""},
+		{"GET http://example.com/?a", 24, "a"},
+		{"GET http://example.com/ X-Api", 24, "X-Api"},
+		{`GET *://**/"a`, 11, `"a`},
+		{`GET http://example.com/ Accept:"text/html`, 31, `"text/html`},
+		{`GET http://example.com/?"q"`, 24, `"q"`},
+	}
+	for i, c := range errors {
+		_, err := ParseRoute(c.s)
+		e, ok := err.(*x.ParseError)
+		if !ok {
+			t.Errorf("bad error case %d: expected a parse error, got %v", i+1, err)
+			continue
+		}
+		if e.Offset != c.offset || e.Part != c.part {
+			t.Errorf("bad error case %d: expected %q at %d, got %q at %d", i+1, c.part, c.offset, e.Part, e.Offset)
+		}
+	}
+}
+
+func TestRouter_RoutesString(t *testing.T) {
+	for i, caseSensitive := range []bool{false, true} {
+		router := NewRouter()
+		router.CaseSensitive = caseSensitive
+		route := Route{
+			Path:    "/Users/{ID}",
+			Headers: map[string]string{"Accept": "text/html, application/json"},
+			Query:   map[string]string{"q": "a b"},
+		}
+		if _, err := router.HandleFunc(route, func(http.ResponseWriter, *http.Request) {}); err != nil {
+			t.Fatal(err)
+		}
+
+		routes := router.Routes()
+		if len(routes) != 1 {
+			t.Fatalf("bad case %d: unexpected routes %v", i+1, routes)
+		}
+		expected := `* *://**:*/users/{ID}?q="a b" accept:"text/html, application/json"`
+		if caseSensitive {
+			expected = `* *://**:*/Users/{ID}?q="a b" accept:"text/html, application/json"`
+		}
+		s := routes[0].String()
+		if s != expected {
+			t.Errorf("bad case %d: expected %q, got %q", i+1, expected, s)
+		}
+		if r, err := ParseRoute(s); err != nil || r.String() != s {
+			t.Errorf("bad case %d: expected %q, got %q (%v)", i+1, s, r.String(), err)
+		}
+	}
+}
+
+func TestRouter_Explain(t *testing.T) {
+	router := NewRouter()
+	router.RedirectTrailingSlash = true
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, c := range []Route{
+		{Path: "/v4/**/x"},
+		{Path: "/v4/*/**/x"},
+		{Method: "POST", Path: "/v4/a/b/y"},
+	} {
+		if _, err := router.Handle(c, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := router.UseMeta(Route{}, x.Meta{Name: "all"}, MiddlewareFunc(func(h http.Handler) http.Handler { return h })); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := router.HandleNotFound(Route{Path: "/v4/**"}, h); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path            string
+		handler         string
+		allow           []string
+		notFound        string
+		redirect        string
+		matched, missed []string
+	}{
+		{"/v4/a/b/x", "* *://**:*/v4/*/**/x", nil, "", "", []string{"/v4/*/**/x", "/v4/**/x", "**"}, nil},
+		{"/v4/a/b/y", "", []string{"POST"}, "", "", []string{"**"}, []string{"/v4/*/**/x", "/v4/**/x"}},
+		{"/v4/c", "", nil, "* *://**:*/v4/**", "", []string{"**"}, []string{"/v4/*/**/x", "/v4/**/x"}},
+		{"/v4/a/b/x/", "", nil, "", "/v4/a/b/x", []string{"**"}, []string{"/v4/*/**/x", "/v4/**/x"}},
+	}
+	for i, c := range cases {
+		e, err := router.Explain(httptest.NewRequest("GET", c.path, nil))
+		if err != nil {
+			t.Fatalf("bad case %d: %v", i+1, err)
+		}
+
+		var handler, notFound string
+		if e.Handler != nil {
+			handler = e.Handler.String()
+		}
+		if e.NotFound != nil {
+			notFound = e.NotFound.String()
+		}
+		if handler != c.handler || notFound != c.notFound || e.Redirect != c.redirect || !reflect.DeepEqual(e.Allow, c.allow) {
+			t.Errorf("bad case %d: unexpected explanation %q %q %q %v", i+1, handler, notFound, e.Redirect, e.Allow)
+		}
+		if c.redirect == "" && (len(e.Middleware) != 1 || e.Middleware[0].Meta[0].Name != "all") {
+			t.Errorf("bad case %d: unexpected middleware %v", i+1, e.Middleware)
+		}
+
+		var matched, missed []string
+		for _, step := range e.Steps {
+			if step.Level != pathLevel || step.Path[hostLevel].StringWith(".") != "**" {
+				continue
+			}
+			for j, candidate := range step.Candidates {
+				if candidate.Matched {
+					if candidate.Order != j {
+						t.Errorf("bad case %d: expected order %d, got %d", i+1, j, candidate.Order)
+					}
+					matched = append(matched, candidate.Key.StringWith("/"))
+				} else {
+					missed = append(missed, candidate.Key.StringWith("/"))
+				}
+			}
+		}
+		if !reflect.DeepEqual(matched, c.matched) || !reflect.DeepEqual(missed, c.missed) {
+			t.Errorf("bad case %d: expected %v and %v, got %v and %v", i+1, c.matched, c.missed, matched, missed)
+		}
+	}
+}
+
+func TestRouter_Analyze(t *testing.T) {
+	router := NewRouter()
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, c := range []Route{
+		{Path: "/v4/**/x"},
+		{Path: "/v4/*/**/x"},
+		{Path: "/users/{id}"},
+		{Path: "/users/*"},
+		{Path: "/r/{re:^.*$}"},
+		{Path: "/r/x*"},
+		{Path: "/x"},
+		{Path: "/x"},
+		{Host: "*.example.com", Path: "/h"},
+		{Host: "a.example.*", Path: "/h"},
+		{Method: "GET", Path: "/a/b"},
+		{Path: "/a/*"},
+		{Path: "/api/**"},
+		{Method: "GET", Path: "/api/v1"},
+	} {
+		if _, err := router.Handle(c, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// middleware never conflicts
+	if _, err := router.UseFunc(Route{Path: "/x"}, func(h http.Handler) http.Handler { return h }); err != nil {
+		t.Fatal(err)
+	}
+
+	var issues []string
+	for _, issue := range router.Analyze() {
+		issues = append(issues, issue.String())
+	}
+	sort.Strings(issues)
+
+	expected := []string{
+		"* *://**:*/r/x* is unreachable, shadowed by * *://**:*/r/{re:^.*$}",
+		"* *://**:*/users/{id} duplicates * *://**:*/users/*",
+		"* *://**:*/v4/**/x overlaps * *://**:*/v4/*/**/x ambiguously",
+		"* *://**:*/x is registered more than once",
+		"* *://*.example.com:*/h overlaps * *://a.example.*:*/h ambiguously",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("expected %q, got %q", expected, issues)
+	}
+}
+
+func TestRouter_AnalyzePools(t *testing.T) {
+	router := NewRouter()
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	same := http.RedirectHandler("/", http.StatusFound)
+	for _, c := range []struct {
+		path string
+		h    http.Handler
+		opts []HandleOption
+	}{
+		{"/pool", h, []HandleOption{WithWeight(x.NewWeight("blue", 1))}},
+		{"/pool", h, []HandleOption{WithWeight(x.NewWeight("green", 1))}},
+		{"/chain", h, []HandleOption{WithChain(ChainFirstWrite)}},
+		{"/chain", h, nil},
+		{"/same", same, []HandleOption{WithWeight(x.NewWeight("blue", 1))}},
+		{"/same", same, []HandleOption{WithWeight(x.NewWeight("green", 1))}},
+		{"/plain", h, nil},
+		{"/plain", h, nil},
+	} {
+		if _, err := router.Handle(Route{Path: c.path}, c.h, c.opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var issues []string
+	for _, issue := range router.Analyze() {
+		issues = append(issues, issue.String())
+	}
+	sort.Strings(issues)
+
+	expected := []string{
+		"* *://**:*/plain is registered more than once",
+		"* *://**:*/same is registered more than once",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("expected %q, got %q", expected, issues)
+	}
+
+	// every route is a pool with a balancer
+	router.Balancer = new(x.RoundRobin)
+	issues = issues[:0]
+	for _, issue := range router.Analyze() {
+		issues = append(issues, issue.String())
+	}
+	expected = []string{"* *://**:*/same is registered more than once"}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("expected %q with a balancer, got %q", expected, issues)
+	}
+}
+
+func TestRouter_AnalyzeFields(t *testing.T) {
+	router := NewRouter()
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, c := range []Route{
+		{Path: "/q", Query: map[string]string{"format": "json"}},
+		{Path: "/q", Query: map[string]string{"format": "xml"}},
+		{Path: "/q", Query: map[string]string{"page": "1"}},
+		{Path: "/h", Headers: map[string]string{"Accept": "text/html"}},
+		{Path: "/h", Headers: map[string]string{"Accept": "application/json"}},
+		{Path: "/h", Headers: map[string]string{"Accept": "text/*", "X-Version": "2"}},
+		{Path: "/v?/x"},
+		{Path: "/{a,b}/x"},
+		{Path: "/a?/y"},
+		{Path: "/b*/y"},
+	} {
+		if _, err := router.Handle(c, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var issues []string
+	for _, issue := range router.Analyze() {
+		issues = append(issues, issue.String())
+	}
+	sort.Strings(issues)
+
+	expected := []string{
+		"* *://**:*/h accept:text/html overlaps * *://**:*/h accept:text/* x-version:2 ambiguously",
+		"* *://**:*/q?page=1 overlaps * *://**:*/q?format=json ambiguously",
+		"* *://**:*/q?page=1 overlaps * *://**:*/q?format=xml ambiguously",
+		`* *://**:*/{a,b}/x overlaps * *://**:*/"v?/x" ambiguously`,
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("expected %q, got %q", expected, issues)
+	}
+}
+
+func TestRouter_FieldsInjection(t *testing.T) {
+	router := NewRouter()
+	routes := []Route{
+		{Path: "/s", Query: map[string]string{"format": "json"}},
+		{Path: "/h", Headers: map[string]string{"X-Format": "json"}},
+		{Path: "/l", Query: map[string]string{"format": "json"}, UseLiteral: true},
+		{Path: "/c", Query: map[string]string{"a:b": "c"}},
+	}
+	for _, route := range routes {
+		if _, err := router.HandleFunc(route, func(http.ResponseWriter, *http.Request) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		url    string
+		header map[string]string
+		code   int
+	}{
+		{"/s?format=json", nil, http.StatusOK},
+		{"/s?format=xml", nil, http.StatusNotFound},
+		{"/s?q=a%0Aformat:json", nil, http.StatusNotFound},
+		{"/s?q=a%0Aformat%3Ajson", nil, http.StatusNotFound},
+		{"/s?format:json=x", nil, http.StatusNotFound},
+		{"/s?format=xml%0Aformat:json", nil, http.StatusNotFound},
+		{"/h", map[string]string{"X-Format": "json"}, http.StatusOK},
+		{"/h", map[string]string{"X-Other": "a\nx-format:json"}, http.StatusNotFound},
+		{"/h", map[string]string{"X-Format:json": "x"}, http.StatusNotFound},
+		{"/l?format=json", nil, http.StatusOK},
+		{"/l?q=a%0Aformat:json", nil, http.StatusNotFound},
+		{"/c?a:b=c", nil, http.StatusOK},
+		{"/c?a=b:c", nil, http.StatusNotFound},
+	}
+	for i, c := range cases {
+		request := httptest.NewRequest("GET", c.url, nil)
+		for k, v := range c.header {
+			request.Header[k] = []string{v}
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		if w.Code != c.code {
+			t.Errorf("bad case %d: expected %d, got %d", i+1, c.code, w.Code)
+		}
+	}
+}
+
+func TestRouter_BalancerWeights(t *testing.T) {
+	router := NewRouter()
+	blue := x.NewWeight("blue", 2)
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, opts := range [][]HandleOption{{WithWeight(blue)}, nil, {WithChain(ChainFirstWrite)}} {
+		if _, err := router.Handle(Route{Path: "/a"}, h, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := httptest.NewRequest("GET", "/a", nil)
+	route, _, err := router.requestRoute(request, router.source(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := router.Router.Match(route)
+	if len(labels) == 0 || len(labels[0].Weights) != 3 {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	weights := labels[0].Weights
+	if weights[0] != blue || weights[1].Load() != 1 || weights[2].Load() != 1 {
+		t.Fatalf("unexpected weights %v", weights)
+	}
+
+	for i, b := range []x.Balancer{new(x.RoundRobin), new(x.WeightedRoundRobin), x.ConsistentHash{}, x.Random{}} {
+		n := testing.AllocsPerRun(100, func() {
+			if _, ok := x.Select(b, labels[0].Handler, weights, "192.0.2.1"); !ok {
+				t.Fatal("expected a handler")
+			}
+		})
+		if n != 0 {
+			t.Errorf("bad case %d: expected no allocation, got %v", i+1, n)
+		}
+	}
+}
+
 func BenchmarkMatch(b *testing.B) {
 	router := NewRouter()
 	handler := func(w http.ResponseWriter, r *http.Request) {}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Sources of the scheme and host of a request.
+const (
+	// SourceDefault means the value is absent in a request, so a default one is used.
+	SourceDefault = "default"
+	// SourceURL means the value comes from the request URL.
+	SourceURL = "url"
+	// SourceTLS means the scheme is "https" since the request is received over TLS.
+	SourceTLS = "tls"
+	// SourceHost means the host comes from the Host header.
+	SourceHost = "host"
+	// SourceForwarded means the value comes from the RFC 7239 Forwarded header.
+	SourceForwarded = "forwarded"
+	// SourceXForwarded means the value comes from the X-Forwarded-Proto or X-Forwarded-Host header.
+	SourceXForwarded = "x-forwarded"
+)
+
+// SourceValue is the resolved scheme and host of a request, with where they come from.
+type SourceValue struct {
+	Scheme     string
+	SchemeFrom string
+	Host       string
+	HostFrom   string
+}
+
+// Source returns the resolved scheme and host for the current request.
+func Source(r *http.Request) SourceValue {
+	if v := r.Context().Value(sourceKey); v != nil {
+		return v.(SourceValue)
+	}
+	return SourceValue{}
+}
+
+// source resolves the scheme and host of a request, forwarded headers are
+// honored only if the request comes from a trusted proxy.
+func (p *Router) source(req *http.Request) SourceValue {
+	var v SourceValue
+
+	switch {
+	case req.URL.Scheme != "":
+		v.Scheme, v.SchemeFrom = strings.ToLower(req.URL.Scheme), SourceURL
+	case req.TLS != nil:
+		v.Scheme, v.SchemeFrom = "https", SourceTLS
+	default:
+		v.Scheme, v.SchemeFrom = "http", SourceDefault
+	}
+	v.Host, v.HostFrom = req.Host, SourceHost
+
+	if !p.trusted(req.RemoteAddr) {
+		return v
+	}
+
+	if s := lastValue(req.Header["X-Forwarded-Proto"]); s != "" {
+		v.Scheme, v.SchemeFrom = strings.ToLower(s), SourceXForwarded
+	}
+	if s := lastValue(req.Header["X-Forwarded-Host"]); s != "" {
+		v.Host, v.HostFrom = s, SourceXForwarded
+	}
+	if s := lastValue(req.Header["Forwarded"]); s != "" {
+		proto, host := parseForwarded(s)
+		if proto != "" {
+			v.Scheme, v.SchemeFrom = strings.ToLower(proto), SourceForwarded
+		}
+		if host != "" {
+			v.Host, v.HostFrom = host, SourceForwarded
+		}
+	}
+	return v
+}
+
+// trusted returns if the remote address belongs to trusted proxies.
+func (p *Router) trusted(remoteAddr string) bool {
+	if len(p.TrustedProxies) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastValue returns the last element of comma separated header values,
+// which is appended by the nearest proxy.
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	s := values[len(values)-1]
+	if i := strings.LastIndexByte(s, ','); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
+
+// parseForwarded parses a forwarded element, e.g. `for=192.0.2.60;proto=https;host=example.com`.
+func parseForwarded(s string) (proto, host string) {
+	for _, pair := range strings.Split(s, ";") {
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			continue
+		}
+		k, v := strings.TrimSpace(pair[:i]), strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+		switch strings.ToLower(k) {
+		case "proto":
+			proto = v
+		case "host":
+			host = v
+		}
+	}
+	return
+}
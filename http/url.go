@@ -0,0 +1,123 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/vegertar/mux/x"
+	"github.com/vegertar/mux/x/radix"
+)
+
+var (
+	// ErrUnknownRoute resulted from building a URL with a name which is not registered.
+	ErrUnknownRoute = errors.New("unknown route")
+)
+
+// URL builds a URL from the route named by `x.Meta.Name`, see `WithMeta` and `UseMeta`.
+// Patterned fields of the scheme, host, port and path, e.g. `*`, `**` or `{id}`, are filled by
+// params in order, in which a `**` can be filled with multiple segments, e.g. `a/b` in path.
+// Unspecified scheme, host and port are omitted, i.e. a relative URL is returned.
+func (p *Router) URL(name string, params ...string) (*url.URL, error) {
+	route := p.namedRoute(name)
+	if route == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRoute, name)
+	}
+
+	b := urlBuilder{params: params, caseSensitive: p.CaseSensitive}
+	var (
+		u    url.URL
+		host string
+		port string
+		err  error
+	)
+
+	if key := route[schemeLevel]; key.StringWith("") != glob {
+		if u.Scheme, err = b.fill(key, ""); err != nil {
+			return nil, err
+		}
+	}
+	if key := route[hostLevel]; key.StringWith(".") != wildcards {
+		if host, err = b.fill(key, "."); err != nil {
+			return nil, err
+		}
+	}
+	if key := route[portLevel]; key.StringWith("") != glob {
+		if port, err = b.fill(key, ""); err != nil {
+			return nil, err
+		}
+	}
+	if u.Path, err = b.fill(route[pathLevel], "/"); err != nil {
+		return nil, err
+	}
+	if len(b.params) > 0 {
+		return nil, fmt.Errorf("too many params for %q: %q", name, b.params)
+	}
+
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	return &u, nil
+}
+
+// namedRoute returns the first route sequence having metadata with the given name.
+func (p *Router) namedRoute(name string) x.Route {
+	routes, labels := p.Router.RouteLabels()
+	for i, label := range labels {
+		for _, m := range label.Meta {
+			if m.Name == name {
+				return routes[i]
+			}
+		}
+	}
+	return nil
+}
+
+// urlBuilder fills patterns by params in order.
+type urlBuilder struct {
+	params        []string
+	caseSensitive bool
+}
+
+// fill returns the string in which patterned labels of key are replaced by params,
+// the result is validated against the key.
+func (b *urlBuilder) fill(key radix.Key, separator string) (string, error) {
+	fields := make([]string, len(key))
+	for i, label := range key {
+		if label.Literal() {
+			fields[i] = label.String()
+			continue
+		}
+		if len(b.params) == 0 {
+			return "", fmt.Errorf("missing params for %q", key.StringWith(separator))
+		}
+		fields[i], b.params = b.params[0], b.params[1:]
+	}
+
+	s := strings.Join(fields, separator)
+	v := []string{s}
+	if separator != "" {
+		v = strings.Split(s, separator)
+		if !b.caseSensitive || separator == "." {
+			v = lowerLiteralFields(s, separator)
+		}
+	}
+	literal, err := x.NewStringSliceKey(v)
+	if err != nil {
+		return "", err
+	}
+	if !key.Match(literal) {
+		return "", fmt.Errorf("%q doesn't match %q", s, key.StringWith(separator))
+	}
+	return s, nil
+}
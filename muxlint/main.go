@@ -0,0 +1,99 @@
+// Command muxlint reports unreachable, ambiguous and duplicate routes in config files, e.g.
+//
+//	muxlint routes.yaml
+//
+// Each issue is printed in a line, the exit code is 1 if any issue is found, or 2 on errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/vegertar/mux/config"
+	dnsMux "github.com/vegertar/mux/dns"
+	httpMux "github.com/vegertar/mux/http"
+)
+
+var (
+	skipHTTP = flag.Bool("skip-http", false, "skip HTTP routes")
+	skipDNS  = flag.Bool("skip-dns", false, "skip DNS routes")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var found bool
+	for _, name := range flag.Args() {
+		issues, err := lint(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		for _, issue := range issues {
+			fmt.Printf("%s: %s\n", name, issue)
+		}
+		found = found || len(issues) > 0
+	}
+	if found {
+		os.Exit(1)
+	}
+}
+
+// lint analyzes routes of a config file, handlers are placeholders since factories are irrelevant.
+func lint(name string) ([]string, error) {
+	c, err := config.ParseFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []string
+	if !*skipHTTP {
+		router := httpMux.NewRouter()
+		h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+		for _, e := range c.HTTP {
+			route, err := httpMux.ParseRoute(e.Route)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if e.Handler == nil {
+				continue
+			}
+			if _, err := router.Handle(route, h); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		for _, issue := range router.Analyze() {
+			issues = append(issues, "http: "+issue.String())
+		}
+	}
+	if !*skipDNS {
+		router := dnsMux.NewRouter()
+		h := dnsMux.HandlerFunc(func(dnsMux.ResponseWriter, *dnsMux.Request) {})
+		for _, e := range c.DNS {
+			route, err := dnsMux.ParseRoute(e.Route)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if e.Handler == nil {
+				continue
+			}
+			if _, err := router.Handle(route, h); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		for _, issue := range router.Analyze() {
+			issues = append(issues, "dns: "+issue.String())
+		}
+	}
+	return issues, nil
+}
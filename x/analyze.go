@@ -0,0 +1,205 @@
+package x
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// IssueKind is the kind of an issue reported by `Router.Analyze`.
+type IssueKind int
+
+const (
+	// Unreachable means handlers of a route are never the first matched, since another route
+	// with handlers matches all of its requests and takes priority by the pattern order.
+	Unreachable IssueKind = iota + 1
+	// Ambiguous means two routes with handlers match some requests in common while neither
+	// covers the other, so which one handles the common requests is decided by the pattern order.
+	Ambiguous
+	// Duplicate means a route is registered more than once unintentionally, e.g. not as a pool
+	// or a chain of handlers, or it has equivalent patterns of another one, e.g. `{id}` and `*`.
+	Duplicate
+)
+
+// String returns the string representation.
+func (k IssueKind) String() string {
+	switch k {
+	case Unreachable:
+		return "unreachable"
+	case Ambiguous:
+		return "ambiguous"
+	case Duplicate:
+		return "duplicate"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(k))
+}
+
+// Format describes an issue of a route caused by another one.
+func (k IssueKind) Format(route, other string) string {
+	switch k {
+	case Unreachable:
+		return fmt.Sprintf("%s is unreachable, shadowed by %s", route, other)
+	case Ambiguous:
+		return fmt.Sprintf("%s overlaps %s ambiguously", route, other)
+	case Duplicate:
+		if route == other {
+			return fmt.Sprintf("%s is registered more than once", route)
+		}
+		return fmt.Sprintf("%s duplicates %s", route, other)
+	}
+	return fmt.Sprintf("%s: %s and %s", k, route, other)
+}
+
+// Issue is a problem of routes reported by `Router.Analyze`.
+type Issue[H, M any] struct {
+	Kind IssueKind
+	// Label carries the handlers having the issue caused by Other,
+	// which is the same as Label if registered more than once.
+	Label, Other *Label[H, M]
+}
+
+// Analyze reports issues of routes with handlers, in which a route covers another one if it matches
+// all requests of the other at every level by `radix.Key.Cover`, and the priority of routes is decided
+// at the first different level by `radix.Key.Less`. Only handlers of the first matched route are considered.
+// A route registered more than once is reported as a duplicate if duplicated returns true for its label,
+// or always if duplicated is nil.
+func (p *Router[H, M]) Analyze(duplicated func(*Label[H, M]) bool) []Issue[H, M] {
+	var (
+		out    []Issue[H, M]
+		labels []*Label[H, M]
+		routes []Route
+	)
+
+	_, all := p.RouteLabels()
+	for _, label := range all {
+		if len(label.Handler) == 0 {
+			continue
+		}
+		labels = append(labels, label)
+		routes = append(routes, label.Route())
+		if label.registrations() > 1 && (duplicated == nil || duplicated(label)) {
+			out = append(out, Issue[H, M]{Duplicate, label, label})
+		}
+	}
+
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			a, b := routes[i], routes[j]
+			if len(a) != len(b) {
+				continue
+			}
+
+			covers, covered := true, true
+			overlap := true
+			for k := range a {
+				if a[k].Equal(b[k]) {
+					continue
+				}
+				if !a[k].Overlap(b[k]) {
+					overlap = false
+					break
+				}
+				covers = covers && a[k].Cover(b[k])
+				covered = covered && b[k].Cover(a[k])
+			}
+			if !overlap {
+				continue
+			}
+
+			var issue Issue[H, M]
+			switch {
+			case covers && covered:
+				issue = Issue[H, M]{Duplicate, labels[j], labels[i]}
+			case covers && precedes(a, b):
+				issue = Issue[H, M]{Unreachable, labels[j], labels[i]}
+			case covered && precedes(b, a):
+				issue = Issue[H, M]{Unreachable, labels[i], labels[j]}
+			case (covers || covered) && (precedes(a, b) || precedes(b, a)):
+				// the more specific one takes priority
+				continue
+			default:
+				// either they overlap partially, or the order of them is undecided
+				issue = Issue[H, M]{Ambiguous, labels[j], labels[i]}
+			}
+			out = append(out, issue)
+		}
+	}
+
+	return out
+}
+
+// precedes returns if route x takes priority over y at the first different level.
+func precedes(x, y Route) bool {
+	for i := range x {
+		if !x[i].Equal(y[i]) {
+			return x[i].Less(y[i])
+		}
+	}
+	return false
+}
+
+// Unwrapper is implemented by handlers wrapping the one given to registering, e.g. carrying options,
+// by which `Label.SameHandlers` finds the same handler registered again.
+type Unwrapper[H any] interface {
+	Unwrap() H
+}
+
+// SameHandlers returns if any handler of the label is registered more than once, in which handlers
+// are unwrapped by `Unwrapper`, and compared by pointers since functions are incomparable.
+func (p *Label[H, M]) SameHandlers() bool {
+	for i, a := range p.Handler {
+		for _, b := range p.Handler[i+1:] {
+			if sameHandler(unwrapHandler(a), unwrapHandler(b)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Weighted returns if any handler of the label carries a weight, i.e. it's a member of a pool.
+func (p *Label[H, M]) Weighted() bool {
+	for _, h := range p.Handler {
+		if v, ok := any(h).(Weighted); ok && v.Weight() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func sameHandler(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() || va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Chan:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Func:
+		return false
+	}
+	return va.Comparable() && a == b
+}
+
+func unwrapHandler[H any](h H) H {
+	for {
+		v, ok := any(h).(Unwrapper[H])
+		if !ok {
+			return h
+		}
+		h = v.Unwrap()
+	}
+}
+
+// registrations returns the number of registrations of handlers.
+func (p *Label[H, M]) registrations() int {
+	live := p
+	if p.Node != nil {
+		if v := p.Node.Get(p.Key, false, nil); v != nil {
+			live = v
+		}
+	}
+
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	return live.h.Len()
+}
@@ -0,0 +1,202 @@
+package x
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+)
+
+type (
+	// Weight is the relative weight of a pooled handler, which can be adjusted at runtime.
+	// A handler with a non-positive weight is drained from the pool.
+	Weight struct {
+		name string
+		n    atomic.Int64
+	}
+
+	// Weighted is implemented by handlers carrying a weight.
+	Weighted interface {
+		Weight() *Weight
+	}
+
+	// Balancer selects a handler from pooled weights, the key is used for affinity if required.
+	// It returns the index of the selected weight, or -1 if none is available.
+	Balancer interface {
+		Select(weights []*Weight, key string) int
+	}
+
+	// RoundRobin selects available handlers in turn, weights are ignored except for draining.
+	RoundRobin struct {
+		n atomic.Uint64
+	}
+
+	// WeightedRoundRobin selects handlers in turn in proportion to their weights.
+	WeightedRoundRobin struct {
+		n atomic.Uint64
+	}
+
+	// ConsistentHash selects the same handler for the same key as long as the pool is unchanged,
+	// by using weighted rendezvous hashing on names of weights.
+	ConsistentHash struct{}
+
+	// Random selects handlers randomly in proportion to their weights.
+	Random struct{}
+)
+
+// NewWeight creates a weight, the name identifies the handler for consistent hashing,
+// if empty, the index of the handler in the pool is used instead.
+func NewWeight(name string, n int) *Weight {
+	w := &Weight{name: name}
+	w.n.Store(int64(n))
+	return w
+}
+
+// Name returns the name.
+func (w *Weight) Name() string {
+	return w.name
+}
+
+// Load returns the current weight.
+func (w *Weight) Load() int {
+	return int(w.n.Load())
+}
+
+// Store sets the weight.
+func (w *Weight) Store(n int) {
+	w.n.Store(int64(n))
+}
+
+// Select implements the `Balancer` interface.
+func (b *RoundRobin) Select(weights []*Weight, key string) int {
+	var available int
+	for _, w := range weights {
+		if w.Load() > 0 {
+			available++
+		}
+	}
+	if available == 0 {
+		return -1
+	}
+
+	// weights might be changed meanwhile, so falls back to the last available one
+	selected, n := -1, int((b.n.Add(1)-1)%uint64(available))
+	for i, w := range weights {
+		if w.Load() > 0 {
+			if selected = i; n == 0 {
+				break
+			}
+			n--
+		}
+	}
+	return selected
+}
+
+// Select implements the `Balancer` interface.
+func (b *WeightedRoundRobin) Select(weights []*Weight, key string) int {
+	total := totalWeight(weights)
+	if total == 0 {
+		return -1
+	}
+	return pick(weights, int((b.n.Add(1)-1)%uint64(total)))
+}
+
+// Select implements the `Balancer` interface.
+func (ConsistentHash) Select(weights []*Weight, key string) int {
+	selected, best := -1, math.Inf(-1)
+	for i, w := range weights {
+		n := w.Load()
+		if n <= 0 {
+			continue
+		}
+
+		name := w.Name()
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		// maps the hash into (0, 1)
+		u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		if score := float64(n) / -math.Log(u); score > best {
+			selected, best = i, score
+		}
+	}
+	return selected
+}
+
+// Select implements the `Balancer` interface.
+func (Random) Select(weights []*Weight, key string) int {
+	total := totalWeight(weights)
+	if total == 0 {
+		return -1
+	}
+	return pick(weights, rand.IntN(total))
+}
+
+func totalWeight(weights []*Weight) (total int) {
+	for _, w := range weights {
+		if n := w.Load(); n > 0 {
+			total += n
+		}
+	}
+	return
+}
+
+// pick returns the index in which the cumulative weight exceeds n.
+func pick(weights []*Weight, n int) int {
+	for i, w := range weights {
+		if v := w.Load(); v > 0 {
+			if n < v {
+				return i
+			}
+			n -= v
+		}
+	}
+	return -1
+}
+
+// Weights returns weights of handlers in a pool. A handler is weighted 1 without a name unless it
+// implements the `Weighted` interface, or 0 if nil. Since weights are allocated, they are supposed
+// to be computed once handlers are changed rather than for each request.
+func Weights[H any](handlers []H) []*Weight {
+	weights := make([]*Weight, len(handlers))
+	for i, v := range handlers {
+		if w := weightOf(v); w != nil {
+			weights[i] = w
+		} else if any(v) == nil {
+			weights[i] = NewWeight("", 0)
+		} else {
+			weights[i] = NewWeight("", 1)
+		}
+	}
+	return weights
+}
+
+// Select returns a handler selected by the balancer, weights are aligned with handlers, or computed
+// by `Weights` if nil. A single handler without a weight is returned directly without consulting the
+// balancer.
+func Select[H any](b Balancer, handlers []H, weights []*Weight, key string) (h H, ok bool) {
+	if len(handlers) == 1 && weightOf(handlers[0]) == nil {
+		return handlers[0], any(handlers[0]) != nil
+	}
+	if weights == nil {
+		weights = Weights(handlers)
+	}
+
+	if i := b.Select(weights, key); i >= 0 && i < len(handlers) {
+		return handlers[i], true
+	}
+	return
+}
+
+// weightOf returns the weight of a handler if it implements the `Weighted` interface.
+func weightOf[H any](h H) *Weight {
+	if w, ok := any(h).(Weighted); ok {
+		return w.Weight()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package x
+
+import (
+	"github.com/vegertar/mux/x/radix"
+)
+
+// Trace records the decision path of matching a route.
+type Trace struct {
+	// Steps are nodes visited in order.
+	Steps []Step
+
+	fallback string
+}
+
+// Step is a node visited while matching a level of a route.
+type Step struct {
+	// Level is the index of the route level.
+	Level int
+	// Path are pattern keys of levels from the root to the node.
+	Path Route
+	// Key is the key of the route at the level.
+	Key radix.Key
+	// Fallback is the name of the fallback in which the node is visited, e.g. `CNAME` for a DNS node
+	// looking up CNAME records of a name without the questioned data, or empty if not a fallback.
+	Fallback string
+	// Candidates are all edges of the node, the matched ones are in the front.
+	Candidates []Candidate
+}
+
+// Candidate is an edge tried while matching a level.
+type Candidate struct {
+	Key     radix.Key
+	Matched bool
+	// Order is the position in the pattern order of matched edges, in which the first has priority,
+	// or -1 if not matched.
+	Order int
+}
+
+// Explanation is the result of `Router.Explain`.
+type Explanation[H, M any] struct {
+	Trace
+	// Labels are the matched labels, the same as the result of `Router.Match`.
+	Labels []*Label[H, M]
+}
+
+// Fallback records steps of f as the given fallback, it's safe to call with a nil trace.
+func (t *Trace) Fallback(name string, f func()) {
+	if t == nil {
+		f()
+		return
+	}
+
+	old := t.fallback
+	t.fallback = name
+	f()
+	t.fallback = old
+}
+
+// Explain matches a route like `Match`, along with the decision path.
+func (p *Router[H, M]) Explain(r Route) *Explanation[H, M] {
+	e := new(Explanation[H, M])
+	e.Labels = p.root().Explain(r, &e.Trace)
+	return e
+}
+
+// record appends a step of a node which matched the key.
+func record[H, M any](t *Trace, up *Label[H, M], key radix.Key, tree *radix.Tree[*Label[H, M]], match []radix.Leaf[*Label[H, M]]) {
+	var step Step
+	if up != nil {
+		step.Path = up.Route()
+		step.Level = len(step.Path)
+	}
+	step.Key = key
+	step.Fallback = t.fallback
+
+	matched := make(map[*Label[H, M]]bool, len(match))
+	for i, v := range match {
+		matched[v.Value] = true
+		step.Candidates = append(step.Candidates, Candidate{Key: v.Key, Matched: true, Order: i})
+	}
+	tree.Walk(func(leaf radix.Leaf[*Label[H, M]]) bool {
+		if !matched[leaf.Value] {
+			step.Candidates = append(step.Candidates, Candidate{Key: leaf.Key, Order: -1})
+		}
+		return false
+	})
+
+	t.Steps = append(t.Steps, step)
+}
@@ -0,0 +1,133 @@
+package x
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Inflight counts in-flight requests, by which a closed handler can be drained.
+type Inflight struct {
+	n        atomic.Int64
+	draining atomic.Bool
+	ch       atomic.Pointer[chan struct{}]
+}
+
+// Enter records a request is started.
+func (p *Inflight) Enter() {
+	p.n.Add(1)
+}
+
+// TryEnter records a request is started unless draining, in which case the handler is supposed to
+// be unloaded, and the request should be matched again.
+func (p *Inflight) TryEnter() bool {
+	p.n.Add(1)
+	if p.draining.Load() {
+		p.Leave()
+		return false
+	}
+	return true
+}
+
+// Leave records a request is finished.
+func (p *Inflight) Leave() {
+	if p.n.Add(-1) == 0 && p.draining.Load() {
+		select {
+		case p.signal() <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Len returns the number of in-flight requests.
+func (p *Inflight) Len() int {
+	return int(p.n.Load())
+}
+
+// Wait blocks until no request is in flight, or returns the error of the context if it's done first.
+func (p *Inflight) Wait(ctx context.Context) error {
+	p.draining.Store(true)
+	for {
+		if p.n.Load() <= 0 {
+			return nil
+		}
+		select {
+		case <-p.signal():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// signal returns the channel notified when the last request is finished during draining.
+func (p *Inflight) signal() chan struct{} {
+	if ch := p.ch.Load(); ch != nil {
+		return *ch
+	}
+	ch := make(chan struct{}, 1)
+	if p.ch.CompareAndSwap(nil, &ch) {
+		return ch
+	}
+	return *p.ch.Load()
+}
+
+// Pins are in-flight references taken when handlers are selected for a request, rather than when
+// they're started, so that a handler is never started after it's drained. Methods are safe to call
+// with nil pins.
+type Pins struct {
+	mu sync.Mutex
+	v  []*Inflight
+}
+
+// Add takes a reference, it returns false if the handler is draining.
+func (p *Pins) Add(in *Inflight) bool {
+	if !in.TryEnter() {
+		return false
+	}
+	p.mu.Lock()
+	p.v = append(p.v, in)
+	p.mu.Unlock()
+	return true
+}
+
+// Has returns if a reference is taken.
+func (p *Pins) Has(in *Inflight) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return contains(p.v, in)
+}
+
+// Keep releases all references except the given ones, e.g. once a handler is selected from a pool.
+func (p *Pins) Keep(in ...*Inflight) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v := p.v[:0]
+	for _, x := range p.v {
+		if contains(in, x) {
+			v = append(v, x)
+		} else {
+			x.Leave()
+		}
+	}
+	p.v = v
+}
+
+// Release releases all references.
+func (p *Pins) Release() {
+	p.Keep()
+}
+
+func contains(v []*Inflight, in *Inflight) bool {
+	for _, x := range v {
+		if x == in {
+			return true
+		}
+	}
+	return false
+}
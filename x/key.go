@@ -26,11 +26,14 @@ func NewStringSliceKey(v []string) (radix.Key, error) {
 }
 
 // NewGlobKey creates a glob patterned key from a string with a separator.
+// Separators enclosed in braces, e.g. in regular expressions, are not splitted.
 func NewGlobKey(s, separator string) (radix.Key, error) {
-	return NewGlobSliceKey(strings.Split(s, separator))
+	return NewGlobSliceKey(radix.SplitPattern(s, separator))
 }
 
 // NewGlobSliceKey creates a glob patterned key from a string slice.
+// A field written as `{re:EXPR}` is created as a regular expression, `{:type}` as a registered type,
+// and `{name}`, `:name`, `{name:re:EXPR}` or `{name:type}` as a named field.
 func NewGlobSliceKey(v []string) (radix.Key, error) {
 	key := make(radix.Key, 0, len(v))
 	for _, s := range v {
@@ -73,8 +76,17 @@ func (p *globLabel) Wildcards() bool {
 	return p.wildcards
 }
 
-// NewLabel creates an empty label from either a glob pattern text or a string literal.
+// NewLabel creates an empty label from either a glob pattern text, a regular expression,
+// a type, a named field or a string literal.
 func NewLabel(s string) (radix.Label, error) {
+	if radix.IsPattern(s) {
+		key, err := radix.NewPatternSliceKey([]string{s})
+		if err != nil {
+			return nil, err
+		}
+		return key[0], nil
+	}
+
 	p := new(globLabel)
 	p.text = s
 	if s != "" && glob.QuoteMeta(s) != s {
@@ -95,3 +107,16 @@ func NewLiteralLabel(s string) (radix.Label, error) {
 		text: s,
 	}, nil
 }
+
+// MapPattern returns a copy of v with f applied to all fields except
+// the ones should be kept as is, e.g. regular expressions and named fields.
+func MapPattern(v []string, f func(string) string) []string {
+	out := make([]string, 0, len(v))
+	for _, s := range v {
+		if !radix.IsPattern(s) {
+			s = f(s)
+		}
+		out = append(out, s)
+	}
+	return out
+}
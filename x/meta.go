@@ -0,0 +1,52 @@
+package x
+
+import (
+	"time"
+)
+
+// Meta is the metadata of handlers or middleware, which tells who registered what.
+type Meta struct {
+	// Name identifies the registration, e.g. `users.list`.
+	Name string
+	// Tags are used to group and query registrations.
+	Tags []string
+	// Owner is the team or the component owning the registration.
+	Owner string
+	// Description is a human readable description.
+	Description string
+	// Created is the creation time, which is filled at registering if zero.
+	Created time.Time
+}
+
+// HasTag returns if the metadata has the given tag.
+func (m Meta) HasTag(tag string) bool {
+	for _, v := range m.Tags {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTag returns if any of metadata has the given tag.
+func HasTag(meta []Meta, tag string) bool {
+	for _, m := range meta {
+		if m.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// newMeta returns a copy of the metadata with the creation time filled.
+func newMeta(meta *Meta) *Meta {
+	if meta == nil {
+		return nil
+	}
+	m := *meta
+	m.Tags = append([]string(nil), meta.Tags...)
+	if m.Created.IsZero() {
+		m.Created = time.Now()
+	}
+	return &m
+}
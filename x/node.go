@@ -9,46 +9,76 @@ import (
 )
 
 // Label is used to represent a value.
-type Label struct {
-	Value
+type Label[H, M any] struct {
+	Value[H, M]
 	Key radix.Key
 
 	h  list.List
 	m  list.List
-	mu sync.RWMutex
+	mu sync.Mutex
+	// v holds the latest published value which can be read without locks.
+	v atomic.Pointer[Value[H, M]]
 }
 
 // Clone returns a shadow copy
-func (p *Label) Clone() *Label {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	var v Label
+func (p *Label[H, M]) Clone() *Label[H, M] {
+	var v Label[H, M]
 	v.Key = p.Key
-	v.Value = p.Value
+	if value := p.v.Load(); value != nil {
+		v.Value = *value
+	} else {
+		v.Value = p.Value
+	}
 
 	return &v
 }
 
-func (p *Label) getDown(breed BreedFunc) Node {
-	p.mu.RLock()
-	down := p.Down
-	p.mu.RUnlock()
-	if down == nil {
-		down = breed(p)
-		p.mu.Lock()
-		if p.Down == nil {
-			p.Down = down
-		} else {
-			down = p.Down
+// Route returns the pattern keys of all levels from the root to this label.
+func (p *Label[H, M]) Route() Route {
+	var route []radix.Key
+
+	for leaf := p; ; {
+		route = append(route, leaf.Key)
+		if leaf.Node == nil {
+			break
+		}
+		up := leaf.Node.Up()
+		if up == nil {
+			break
 		}
-		p.mu.Unlock()
+		leaf = up.Clone()
+	}
+	for i, j := 0, len(route)-1; i < j; i, j = i+1, j-1 {
+		route[i], route[j] = route[j], route[i]
+	}
+	return route
+}
+
+// publish makes the current value visible to readers, the caller should hold the lock.
+func (p *Label[H, M]) publish() {
+	v := p.Value
+	p.v.Store(&v)
+}
+
+func (p *Label[H, M]) getDown(breed BreedFunc[H, M]) Node[H, M] {
+	if down := p.Clone().Down; down != nil {
+		return down
+	}
+
+	down := breed(p)
+	p.mu.Lock()
+	if p.Down == nil {
+		p.Down = down
+		p.publish()
+	} else {
+		down = p.Down
 	}
+	p.mu.Unlock()
 	return down
 }
 
 // free delete all trivial labels down to up
-func (p *Label) free() {
+func (p *Label[H, M]) free() {
 	for v := p; v != nil &&
 		len(v.Handler) == 0 &&
 		len(v.Middleware) == 0 &&
@@ -57,36 +87,94 @@ func (p *Label) free() {
 	}
 }
 
-func (p *Label) setupHandler(h []interface{}) CloseFunc {
+// entry is an element of handlers or middleware lists.
+type entry[T any] struct {
+	values []T
+	meta   *Meta
+}
+
+func (p *Label[H, M]) setupHandler(h []H, meta *Meta) *Registration[H] {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	elem := p.h.PushBack(entry[H]{h, meta})
 	p.Handler = append(p.Handler, h...)
-	elem := p.h.PushBack(h)
+	p.Weights = Weights(p.Handler)
+	p.resetMeta()
+	p.publish()
+
+	var (
+		closed bool
+		r      = new(Registration[H])
+	)
+	r.swap = func(h []H, update bool, m *Meta) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
 
-	var closed int32
-	return func() {
-		if atomic.CompareAndSwapInt32(&closed, 0, 1) {
-			p.mu.Lock()
-			defer p.mu.Unlock()
+		if closed {
+			return ErrClosedRegistration
+		}
+		if update {
+			meta = m
+		}
+		// replaces in place so that readers see either the old or the new handlers
+		elem.Value = entry[H]{h, meta}
+		p.resetHandler()
+		if update {
+			p.resetMeta()
+		}
+		p.publish()
+		return nil
+	}
+	r.close = func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		closed = true
+		p.h.Remove(elem)
+		p.resetHandler()
+		p.resetMeta()
+		p.publish()
+		p.free()
+	}
+	return r
+}
 
-			p.h.Remove(elem)
-			p.Handler = p.Handler[:0]
-			for e := p.h.Front(); e != nil; e = e.Next() {
-				p.Handler = append(p.Handler, e.Value.([]interface{})...)
-			}
+// resetHandler rebuilds handlers from the list, the caller should hold the lock.
+func (p *Label[H, M]) resetHandler() {
+	// never reuses the slice which might be held by readers
+	var handler []H
+	for e := p.h.Front(); e != nil; e = e.Next() {
+		handler = append(handler, e.Value.(entry[H]).values...)
+	}
+	p.Handler = handler
+	p.Weights = Weights(handler)
+}
 
-			p.free()
+// resetMeta rebuilds metadata from lists of handlers and middleware, the caller should hold the lock.
+func (p *Label[H, M]) resetMeta() {
+	var meta []Meta
+	for e := p.h.Front(); e != nil; e = e.Next() {
+		if v := e.Value.(entry[H]).meta; v != nil {
+			meta = append(meta, *v)
+		}
+	}
+	for e := p.m.Front(); e != nil; e = e.Next() {
+		if v := e.Value.(entry[M]).meta; v != nil {
+			meta = append(meta, *v)
 		}
 	}
+	p.Meta = meta
 }
 
-func (p *Label) setupMiddleware(m []interface{}) CloseFunc {
+func (p *Label[H, M]) setupMiddleware(m []M, meta *Meta) CloseFunc {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	elem := p.m.PushBack(entry[M]{m, meta})
 	p.Middleware = append(p.Middleware, m...)
-	elem := p.m.PushBack(m)
+	p.resetMeta()
+	p.publish()
 
 	var closed int32
 	return func() {
@@ -95,10 +183,14 @@ func (p *Label) setupMiddleware(m []interface{}) CloseFunc {
 			defer p.mu.Unlock()
 
 			p.m.Remove(elem)
-			p.Middleware = p.Middleware[:0]
+			// never reuses the slice which might be held by readers
+			var middleware []M
 			for e := p.m.Front(); e != nil; e = e.Next() {
-				p.Middleware = append(p.Middleware, e.Value.([]interface{})...)
+				middleware = append(middleware, e.Value.(entry[M]).values...)
 			}
+			p.Middleware = middleware
+			p.resetMeta()
+			p.publish()
 
 			p.free()
 		}
@@ -107,71 +199,92 @@ func (p *Label) setupMiddleware(m []interface{}) CloseFunc {
 
 // Node defines a interface to add, delete, match and iterate a router.
 // A node should implement concurrent safety.
-type Node interface {
+type Node[H, M any] interface {
 	// Get returns a label from this Node.
-	Get(key radix.Key, createIfMissing bool, useNode Node) *Label
+	Get(key radix.Key, createIfMissing bool, useNode Node[H, M]) *Label[H, M]
 
 	// Delete deletes a label.
-	Delete(label *Label)
+	Delete(label *Label[H, M])
 
 	// Up returns a parent label.
-	Up() *Label
+	Up() *Label[H, M]
 
 	// Empty returns if it is empty.
 	Empty() bool
 
 	// Leaves returns all labels has nil `Down` node.
-	Leaves() []*Label
+	Leaves() []*Label[H, M]
+
+	// Children returns all labels of this Node.
+	Children() []*Label[H, M]
 
 	// Match returns all labels matched given route.
-	Match(route Route) []*Label
+	Match(route Route) []*Label[H, M]
+
+	// Explain is like Match, but also records the decision path into the trace if not nil.
+	Explain(route Route, trace *Trace) []*Label[H, M]
 }
 
 // RadixNode uses radix tree to store and search route components.
-type RadixNode struct {
-	tree *radix.Tree
-	up   *Label
-	mu   sync.RWMutex
+// Readers never block, every write publishes a new tree snapshot atomically.
+type RadixNode[H, M any] struct {
+	tree atomic.Pointer[radix.Tree[*Label[H, M]]]
+	up   *Label[H, M]
+	mu   sync.Mutex // serializes writers
 }
 
 // NewRadixNode creates a Node instance.
-func NewRadixNode(up *Label) *RadixNode {
-	return &RadixNode{
-		tree: radix.New(),
-		up:   up,
+func NewRadixNode[H, M any](up *Label[H, M]) *RadixNode[H, M] {
+	p := &RadixNode[H, M]{
+		up: up,
 	}
+	p.tree.Store(radix.New[*Label[H, M]]())
+	return p
+}
+
+func (p *RadixNode[H, M]) load() *radix.Tree[*Label[H, M]] {
+	return p.tree.Load()
 }
 
 // Up implements the `Node` interface.
-func (p *RadixNode) Up() *Label {
+func (p *RadixNode[H, M]) Up() *Label[H, M] {
 	return p.up
 }
 
 // Empty implements the `Node` interface.
-func (p *RadixNode) Empty() bool {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.tree.Len() == 0
+func (p *RadixNode[H, M]) Empty() bool {
+	return p.load().Len() == 0
 }
 
 // Delete implements the `Node` interface.
-func (p *RadixNode) Delete(label *Label) {
+func (p *RadixNode[H, M]) Delete(label *Label[H, M]) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.tree.Delete(label.Key)
+
+	txn := p.load().Txn()
+	if _, ok := txn.Delete(label.Key); ok {
+		p.tree.Store(txn.Commit())
+	}
 }
 
 // Match implements the `Node` interface.
-func (p *RadixNode) Match(route Route) (leaves []*Label) {
+func (p *RadixNode[H, M]) Match(route Route) []*Label[H, M] {
+	return p.Explain(route, nil)
+}
+
+// Explain implements the `Node` interface.
+func (p *RadixNode[H, M]) Explain(route Route, trace *Trace) (leaves []*Label[H, M]) {
 	if len(route) > 0 {
-		p.mu.RLock()
-		match := p.tree.Match(route[0])
-		p.mu.RUnlock()
+		tree := p.load()
+		match := tree.Match(route[0])
+		if trace != nil {
+			record(trace, p.up, route[0], tree, match)
+		}
 
 		for _, v := range match {
-			label := v.Value.(*Label).Clone()
+			label := v.Value.Clone()
 			if len(route) > 1 && label.Down != nil {
-				leaves = append(leaves, label.Down.Match(route[1:])...)
+				leaves = append(leaves, label.Down.Explain(route[1:], trace)...)
 				continue
 			}
 
@@ -194,12 +307,9 @@ func (p *RadixNode) Match(route Route) (leaves []*Label) {
 }
 
 // Leaves implements the `Node` interface.
-func (p *RadixNode) Leaves() (leaves []*Label) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	p.tree.Walk(func(leaf radix.Leaf) bool {
-		label := leaf.Value.(*Label).Clone()
+func (p *RadixNode[H, M]) Leaves() (leaves []*Label[H, M]) {
+	p.load().Walk(func(leaf radix.Leaf[*Label[H, M]]) bool {
+		label := leaf.Value.Clone()
 		if label.Down != nil {
 			leaves = append(leaves, label.Down.Leaves()...)
 		} else {
@@ -211,23 +321,39 @@ func (p *RadixNode) Leaves() (leaves []*Label) {
 	return
 }
 
+// Children implements the `Node` interface.
+func (p *RadixNode[H, M]) Children() (children []*Label[H, M]) {
+	p.load().Walk(func(leaf radix.Leaf[*Label[H, M]]) bool {
+		children = append(children, leaf.Value.Clone())
+		return false
+	})
+
+	return
+}
+
 // Get implements the `Node` interface.
-func (p *RadixNode) Get(k radix.Key, createIfMissing bool, useNode Node) *Label {
-	p.mu.RLock()
-	value, ok := p.tree.Get(k)
-	p.mu.RUnlock()
-	if ok && value != nil {
-		return value.(*Label)
+func (p *RadixNode[H, M]) Get(k radix.Key, createIfMissing bool, useNode Node[H, M]) *Label[H, M] {
+	if label, ok := p.load().Get(k); ok && label != nil {
+		return label
 	}
 
 	if createIfMissing {
-		label := new(Label)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		// checks again since another writer might have created it
+		txn := p.load().Txn()
+		if label, ok := txn.Get(k); ok && label != nil {
+			return label
+		}
+
+		label := new(Label[H, M])
 		label.Key = k
 		label.Node = useNode
+		label.publish()
 
-		p.mu.Lock()
-		p.tree.Insert(k, label)
-		p.mu.Unlock()
+		txn.Insert(k, label)
+		p.tree.Store(txn.Commit())
 		return label
 	}
 
@@ -0,0 +1,27 @@
+package x
+
+import (
+	"fmt"
+)
+
+// ParseError describes a problem parsing a route string, which points at the bad part.
+type ParseError struct {
+	// Input is the route string being parsed.
+	Input string
+	// Offset is the byte offset of the bad part in the input.
+	Offset int
+	// Part is the bad part.
+	Part string
+	// Err is the reason.
+	Err error
+}
+
+// Error implements the `error` interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse route %q: %q at offset %d: %v", e.Input, e.Part, e.Offset, e.Err)
+}
+
+// Unwrap returns the reason.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
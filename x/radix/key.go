@@ -1,10 +1,19 @@
 package radix
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 )
 
-const glob = "*"
+const (
+	glob = "*"
+
+	regexpPrefix  = "{re:"
+	patternPrefix = "{"
+	patternSuffix = "}"
+	paramPrefix   = ":"
+)
 
 // Label is the minimum comparing unit in the tree.
 type Label interface {
@@ -111,6 +120,150 @@ func newGlobLabel(s string) *globLabel {
 	}
 }
 
+// RegexpLabel is the label matched by a regular expression, written as `{re:EXPR}`.
+type RegexpLabel struct {
+	s  string
+	re *regexp.Regexp
+}
+
+// String implements the `Label` interface.
+func (p *RegexpLabel) String() string {
+	return p.s
+}
+
+// Match implements the `Label` interface.
+func (p *RegexpLabel) Match(subj string) bool {
+	return p.re.MatchString(subj)
+}
+
+// Literal implements the `Label` interface.
+func (p *RegexpLabel) Literal() bool {
+	return false
+}
+
+// Wildcards implements the `Label` interface.
+func (p *RegexpLabel) Wildcards() bool {
+	return false
+}
+
+// Regexp returns the underlying regular expression.
+func (p *RegexpLabel) Regexp() *regexp.Regexp {
+	return p.re
+}
+
+// NewRegexpLabel creates a label from a regular expression.
+func NewRegexpLabel(expr string) (*RegexpLabel, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexpLabel{
+		s:  regexpPrefix + expr + patternSuffix,
+		re: re,
+	}, nil
+}
+
+// IsRegexp returns if s is written as a regular expression label, i.e. `{re:EXPR}`.
+func IsRegexp(s string) bool {
+	return len(s) > len(regexpPrefix) &&
+		strings.HasPrefix(s, regexpPrefix) &&
+		strings.HasSuffix(s, patternSuffix)
+}
+
+// ParamLabel is a label matching a single field with a capture name,
+// written as `{name}`, `:name`, `{name:re:EXPR}` or `{name:type}`.
+type ParamLabel struct {
+	Label
+	s    string
+	name string
+}
+
+// String implements the `Label` interface.
+func (p *ParamLabel) String() string {
+	return p.s
+}
+
+// Literal implements the `Label` interface.
+func (p *ParamLabel) Literal() bool {
+	return false
+}
+
+// Wildcards implements the `Label` interface.
+func (p *ParamLabel) Wildcards() bool {
+	return false
+}
+
+// Name returns the capture name.
+func (p *ParamLabel) Name() string {
+	return p.name
+}
+
+// Pattern returns the underlying label to match a field.
+func (p *ParamLabel) Pattern() Label {
+	return p.Label
+}
+
+// NewParamLabel creates a named label, the constraint is the text following the name, e.g. `re:EXPR` or `int`.
+func NewParamLabel(name, constraint string, pattern Label) *ParamLabel {
+	s := patternPrefix + name
+	if constraint != "" {
+		s += ":" + constraint
+	}
+	return &ParamLabel{
+		Label: pattern,
+		s:     s + patternSuffix,
+		name:  name,
+	}
+}
+
+// ParseParam parses a named field written as `{name}`, `:name` or `{name:CONSTRAINT}`,
+// returns the name and the constraint text, e.g. `re:EXPR` or `int`.
+func ParseParam(s string) (name, constraint string, ok bool) {
+	if strings.HasPrefix(s, paramPrefix) {
+		name = s[len(paramPrefix):]
+		return name, "", isIdent(name)
+	}
+
+	if len(s) <= len(patternPrefix)+len(patternSuffix) ||
+		!strings.HasPrefix(s, patternPrefix) ||
+		!strings.HasSuffix(s, patternSuffix) {
+		return "", "", false
+	}
+
+	body := s[len(patternPrefix) : len(s)-len(patternSuffix)]
+	name = body
+	if i := strings.Index(body, ":"); i != -1 {
+		name, constraint = body[:i], body[i+1:]
+	}
+	if name == "re" && constraint != "" || !isIdent(name) {
+		// either a regular expression or a glob, e.g. `{a,b}`
+		return "", "", false
+	}
+	return name, constraint, true
+}
+
+func isIdent(s string) bool {
+	for i, c := range s {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && '0' <= c && c <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// IsPattern returns if s is written within braces as a regular expression, a type or a named field,
+// such a text should be kept as is.
+func IsPattern(s string) bool {
+	if IsRegexp(s) || IsTyped(s) {
+		return true
+	}
+	_, _, ok := ParseParam(s)
+	return ok
+}
+
 // Key is the key to insert, delete, and search a tree.
 type Key []Label
 
@@ -279,6 +432,74 @@ func (k Key) Capture(x Key) []Key {
 	return captures
 }
 
+// Align returns sub-keys of x matched by every label in k, i.e. the result has the same length of k.
+// A wildcards label might match zero or more labels, others match exactly one.
+// Returns nil if `k.Match(x)` is false.
+func (k Key) Align(x Key) []Key {
+	if !k.Match(x) {
+		return nil
+	}
+
+	out := make([]Key, len(k))
+
+	// collects the leading label index of every continued wildcards labels
+	var runs []int
+	for i, label := range k {
+		if label.Wildcards() && (i == 0 || !k[i-1].Wildcards()) {
+			runs = append(runs, i)
+		}
+	}
+
+	if len(runs) == 0 {
+		for i := range k {
+			out[i] = x[i : i+1]
+		}
+		return out
+	}
+
+	pos := 0
+	for i := 0; i <= len(runs); i++ {
+		start, end := 0, len(k)
+		if i > 0 {
+			start = runs[i-1]
+			for start < len(k) && k[start].Wildcards() {
+				start++
+			}
+		}
+		if i < len(runs) {
+			end = runs[i]
+		}
+
+		y := k[start:end]
+		n := len(y)
+		idx := pos
+		switch {
+		case i == len(runs):
+			// the last part matches the suffix
+			idx = len(x) - n
+		case i > 0:
+			// a middle part matches the first occurrence
+			for j := pos; j+n <= len(x); j++ {
+				if y.matchExactly(x[j : j+n]) {
+					idx = j
+					break
+				}
+			}
+		}
+
+		if i > 0 {
+			// the first wildcards label takes all skipped labels
+			out[runs[i-1]] = x[pos:idx]
+		}
+		for j := 0; j < n; j++ {
+			out[start+j] = x[idx+j : idx+j+1]
+		}
+		pos = idx + n
+	}
+
+	return out
+}
+
 // Is returns if key equals given strings.
 func (k Key) Is(x ...string) bool {
 	if len(k) == len(x) {
@@ -388,3 +609,86 @@ func NewGlobSliceKey(v []string) Key {
 	}
 	return labels
 }
+
+// SplitPattern slices s into all substrings separated by separator,
+// separators enclosed in braces or escaped by a backslash are kept,
+// e.g. the ones in `{re:^a.b$}`.
+func SplitPattern(s, separator string) []string {
+	if separator == "" || !strings.ContainsAny(s, "{\\") {
+		return strings.Split(s, separator)
+	}
+
+	var (
+		v     []string
+		depth int
+		last  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		default:
+			if depth == 0 && strings.HasPrefix(s[i:], separator) {
+				v = append(v, s[last:i])
+				last = i + len(separator)
+				i = last - 1
+			}
+		}
+	}
+	return append(v, s[last:])
+}
+
+// NewPatternKey creates a patterned key from a string with a separator,
+// in which every label might be a glob, a regular expression, a type or a named field.
+func NewPatternKey(s, separator string) (Key, error) {
+	return NewPatternSliceKey(SplitPattern(s, separator))
+}
+
+// NewPatternSliceKey creates a patterned key from a string slice,
+// in which every label might be a glob, a regular expression, a type or a named field.
+func NewPatternSliceKey(v []string) (Key, error) {
+	labels := make([]Label, 0, len(v))
+	for _, s := range v {
+		label, err := newPatternLabel(s)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+func newPatternLabel(s string) (Label, error) {
+	if IsRegexp(s) {
+		return NewRegexpLabel(s[len(regexpPrefix) : len(s)-len(patternSuffix)])
+	}
+
+	if IsTyped(s) {
+		return NewTypedLabel(s[len(typedPrefix) : len(s)-len(patternSuffix)])
+	}
+
+	if name, constraint, ok := ParseParam(s); ok {
+		pattern := glob
+		if constraint != "" {
+			// either a regular expression or a type
+			pattern = typedPrefix + constraint + patternSuffix
+			if regexpPattern := patternPrefix + constraint + patternSuffix; IsRegexp(regexpPattern) {
+				pattern = regexpPattern
+			}
+		}
+
+		label, err := newPatternLabel(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad parameter %q: %v", name, err)
+		}
+		return NewParamLabel(name, constraint, label), nil
+	}
+
+	return NewGlobSliceKey([]string{s})[0], nil
+}
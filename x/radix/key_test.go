@@ -2,6 +2,7 @@ package radix
 
 import (
 	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -130,3 +131,196 @@ func TestNewCharKey(t *testing.T) {
 		}
 	}
 }
+
+func TestRegexpLabel_Match(t *testing.T) {
+	type exp struct {
+		expr, s string
+		matched bool
+	}
+
+	cases := []exp{
+		{"^v[0-9]+$", "v1", true},
+		{"^v[0-9]+$", "v12", true},
+		{"^v[0-9]+$", "v", false},
+		{"^v[0-9]+$", "v1x", false},
+		{"shard-[0-9]", "db-shard-1", true},
+	}
+	for _, c := range cases {
+		label, err := NewRegexpLabel(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if label.Match(c.s) != c.matched {
+			t.Errorf("bad case: %v", c)
+		}
+		if s := label.String(); s != "{re:"+c.expr+"}" {
+			t.Errorf("bad string: %v", s)
+		}
+	}
+
+	if _, err := NewRegexpLabel("("); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestSplitPattern(t *testing.T) {
+	type exp struct {
+		s, separator string
+		y            []string
+	}
+
+	cases := []exp{
+		{"", ".", []string{""}},
+		{"a.b", ".", []string{"a", "b"}},
+		{"{re:^a.b$}.c", ".", []string{"{re:^a.b$}", "c"}},
+		{"{re:^a{2}.b$}.c", ".", []string{"{re:^a{2}.b$}", "c"}},
+		{"/v1/{re:^[a-z/]+$}/x", "/", []string{"", "v1", "{re:^[a-z/]+$}", "x"}},
+		{"a\\.b.c", ".", []string{"a\\.b", "c"}},
+	}
+	for i, c := range cases {
+		y := SplitPattern(c.s, c.separator)
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %v: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestKey_Align(t *testing.T) {
+	type exp struct {
+		pattern, x []string
+		y          [][]string
+	}
+
+	cases := []exp{
+		{[]string{"*"}, []string{"ab"}, [][]string{{"ab"}}},
+		{[]string{"a", "*"}, []string{"b", "c"}, nil},
+		{[]string{"a", "*"}, []string{"a", "c"}, [][]string{{"a"}, {"c"}}},
+		{[]string{"**"}, []string{"a", "b"}, [][]string{{"a", "b"}}},
+		{[]string{"a", "**", "b"}, []string{"a", "b"}, [][]string{{"a"}, nil, {"b"}}},
+		{[]string{"a", "**", "**", "b"}, []string{"a", "1", "2", "b"}, [][]string{{"a"}, {"1", "2"}, nil, {"b"}}},
+		{[]string{"**", "*", "b"}, []string{"0", "1", "b"}, [][]string{{"0"}, {"1"}, {"b"}}},
+		{[]string{"a", "**", "*", "**", "c"}, []string{"a", "1", "b", "2", "c"}, [][]string{{"a"}, nil, {"1"}, {"b", "2"}, {"c"}}},
+		{[]string{"**", "a", "**", "b", "**"}, []string{"0", "a", "1", "2", "b", "c"}, [][]string{{"0"}, {"a"}, {"1", "2"}, {"b"}, {"c"}}},
+	}
+	for i, c := range cases {
+		key := NewGlobSliceKey(c.pattern)
+		var y [][]string
+		for _, k := range key.Align(NewStringSliceKey(c.x)) {
+			y = append(y, k.Strings())
+		}
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %d, expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
+func TestParseParam(t *testing.T) {
+	type exp struct {
+		s, name, constraint string
+		ok                  bool
+	}
+
+	cases := []exp{
+		{"{id}", "id", "", true},
+		{":id", "id", "", true},
+		{"{userID}", "userID", "", true},
+		{"{id:re:^[0-9]+$}", "id", "re:^[0-9]+$", true},
+		{"{re:^[0-9]+$}", "", "", false},
+		{"{a,b}", "", "", false},
+		{"{}", "", "", false},
+		{":", "", "", false},
+		{"{1d}", "", "", false},
+		{"id", "", "", false},
+	}
+	for i, c := range cases {
+		name, constraint, ok := ParseParam(c.s)
+		if name != c.name || constraint != c.constraint || ok != c.ok {
+			t.Errorf("bad case %d: expected %v, got %v %v %v", i+1, c, name, constraint, ok)
+		}
+	}
+
+	key, err := NewPatternKey("users/:id/{post:re:^[0-9]+$}", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := key.StringWith("/"); s != "users/{id}/{post:re:^[0-9]+$}" {
+		t.Errorf("bad key: %v", s)
+	}
+	if !key.Match(NewStringKey("users/x/1", "/")) || key.Match(NewStringKey("users/x/y", "/")) {
+		t.Errorf("bad matching")
+	}
+	if _, err := NewPatternKey("users/{id:unknown}", "/"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestTypedLabel_Match(t *testing.T) {
+	type exp struct {
+		typ, s  string
+		matched bool
+	}
+
+	cases := []exp{
+		{"int", "-12", true},
+		{"int", "1x", false},
+		{"uint", "12", true},
+		{"uint", "-12", false},
+		{"hex", "0aF", true},
+		{"hex", "", false},
+		{"hex", "0g", false},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", true},
+		{"uuid", "123e4567e89b12d3a456426614174000", false},
+		{"alpha", "abc", true},
+		{"alpha", "ab1", false},
+		{"alnum", "ab1", true},
+		{"alnum", "ab-1", false},
+	}
+	for _, c := range cases {
+		label, err := NewTypedLabel(c.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if label.Match(c.s) != c.matched {
+			t.Errorf("bad case: %v", c)
+		}
+	}
+
+	if _, err := NewTypedLabel("unknown"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestRegisterType(t *testing.T) {
+	even := func(s string) bool {
+		n, err := strconv.Atoi(s)
+		return err == nil && n%2 == 0
+	}
+	if err := RegisterType("even", even); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { unregisterType("even") })
+
+	if err := RegisterType("even", even); err != ErrExistedType {
+		t.Fatal("expected", ErrExistedType, "got", err)
+	}
+	if err := RegisterType("re", even); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := RegisterType("odd", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := LookupType("odd"); ok {
+		t.Fatal("unexpected type")
+	}
+
+	key, err := NewPatternKey("v/{n:even}/{:int}", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := key.StringWith("/"); s != "v/{n:even}/{:int}" {
+		t.Errorf("bad key: %v", s)
+	}
+	if !key.Match(NewStringKey("v/2/1", "/")) || key.Match(NewStringKey("v/1/1", "/")) {
+		t.Errorf("bad matching")
+	}
+}
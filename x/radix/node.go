@@ -5,65 +5,88 @@ import (
 )
 
 // Leaf is used to represent a value
-type Leaf struct {
+type Leaf[V any] struct {
 	Key   Key
-	Value interface{}
+	Value V
 }
 
-type sortLeafByPattern []Leaf
+type sortLeafByPattern[V any] []Leaf[V]
 
-func (l sortLeafByPattern) Len() int {
+func (l sortLeafByPattern[V]) Len() int {
 	return len(l)
 }
 
-func (l sortLeafByPattern) Less(i, j int) bool {
+func (l sortLeafByPattern[V]) Less(i, j int) bool {
 	return lessKey(l[i].Key, l[j].Key)
 }
 
-func (l sortLeafByPattern) Swap(i, j int) {
+func (l sortLeafByPattern[V]) Swap(i, j int) {
 	l[i], l[j] = l[j], l[i]
 }
 
 // edge is used to represent an edge node
-type edge struct {
+type edge[V any] struct {
 	label Label
-	node  *node
+	node  *node[V]
 }
 
-type sortEdgeByLiteral []edge
+type sortEdgeByLiteral[V any] []edge[V]
 
-func (e sortEdgeByLiteral) Len() int {
+func (e sortEdgeByLiteral[V]) Len() int {
 	return len(e)
 }
 
-func (e sortEdgeByLiteral) Less(i, j int) bool {
+func (e sortEdgeByLiteral[V]) Less(i, j int) bool {
 	return e[i].label.String() < e[j].label.String()
 }
 
-func (e sortEdgeByLiteral) Swap(i, j int) {
+func (e sortEdgeByLiteral[V]) Swap(i, j int) {
 	e[i], e[j] = e[j], e[i]
 }
 
-type sortEdgeByPattern []edge
+type sortEdgeByPattern[V any] []edge[V]
 
-func (e sortEdgeByPattern) Len() int {
+func (e sortEdgeByPattern[V]) Len() int {
 	return len(e)
 }
 
-func (e sortEdgeByPattern) Less(i, j int) bool {
+func (e sortEdgeByPattern[V]) Less(i, j int) bool {
 	return lessLabel(e[i].label, e[j].label)
 }
 
-func (e sortEdgeByPattern) Swap(i, j int) {
+func (e sortEdgeByPattern[V]) Swap(i, j int) {
 	e[i], e[j] = e[j], e[i]
 }
 
+// rankLabel orders labels by specificity: literals, regular expressions or types, and then globs.
+func rankLabel(l Label) int {
+	if p, ok := l.(*ParamLabel); ok {
+		l = p.Pattern()
+	}
+	if l.Literal() {
+		return 0
+	}
+	switch l.(type) {
+	case *RegexpLabel, *TypedLabel:
+		return 1
+	}
+	return 2
+}
+
 func lessLabel(x, y Label) bool {
 	a, b := x.String(), y.String()
 	if x.Literal() && y.Literal() {
 		return a < b
 	}
 
+	if i, j := rankLabel(x), rankLabel(y); i == 1 || j == 1 {
+		// regular expressions and types cannot be compared by matching each other
+		if i != j {
+			return i < j
+		}
+		return a < b
+	}
+
 	if y.Match(a) {
 		// keep the relative order
 		if x.Match(b) {
@@ -88,6 +111,12 @@ func lessKey(x, y Key) bool {
 	return false
 }
 
+// Less returns if the key has priority over another one when both are matched, it's the order
+// in which `Tree.Match` returns leaves.
+func (k Key) Less(x Key) bool {
+	return lessKey(k, x)
+}
+
 func longestPrefix(x, y Key) int {
 	max := len(x)
 	if l := len(y); l < max {
@@ -125,39 +154,39 @@ func isPrefixOfLiteralKey(x, y Key) int {
 	return 0
 }
 
-type node struct {
+type node[V any] struct {
 	// leaf is used to store possible leaf
-	leaf *Leaf
+	leaf *Leaf[V]
 
 	// prefix is the common prefix we ignore
 	prefix Key
 
 	// edges should be stored in-order for iteration and searching.
 	edges struct {
-		literalEdges   []edge
-		patternedEdges []edge
+		literalEdges   []edge[V]
+		patternedEdges []edge[V]
 	}
 }
 
-func (p *node) size() int {
+func (p *node[V]) size() int {
 	return len(p.edges.literalEdges) + len(p.edges.patternedEdges)
 }
 
-func (p *node) isLeaf() bool {
+func (p *node[V]) isLeaf() bool {
 	return p.leaf != nil
 }
 
-func (p *node) addEdge(e edge) {
+func (p *node[V]) addEdge(e edge[V]) {
 	if e.label.Literal() {
 		p.edges.literalEdges = append(p.edges.literalEdges, e)
-		sort.Sort(sortEdgeByLiteral(p.edges.literalEdges))
+		sort.Sort(sortEdgeByLiteral[V](p.edges.literalEdges))
 	} else {
 		p.edges.patternedEdges = append(p.edges.patternedEdges, e)
-		sort.Sort(sortEdgeByLiteral(p.edges.patternedEdges))
+		sort.Sort(sortEdgeByLiteral[V](p.edges.patternedEdges))
 	}
 }
 
-func (p *node) delEdge(l Label) {
+func (p *node[V]) delEdge(l Label) {
 	s := l.String()
 	if l.Literal() {
 		x := p.edges.literalEdges
@@ -166,7 +195,7 @@ func (p *node) delEdge(l Label) {
 		})
 		if i < len(x) && x[i].label.String() == s {
 			copy(p.edges.literalEdges[i:], p.edges.literalEdges[i+1:])
-			p.edges.literalEdges[len(p.edges.literalEdges)-1] = edge{}
+			p.edges.literalEdges[len(p.edges.literalEdges)-1] = edge[V]{}
 			p.edges.literalEdges = p.edges.literalEdges[:len(p.edges.literalEdges)-1]
 		}
 	} else {
@@ -176,13 +205,13 @@ func (p *node) delEdge(l Label) {
 		})
 		if i < len(x) && x[i].label.String() == s {
 			copy(p.edges.patternedEdges[i:], p.edges.patternedEdges[i+1:])
-			p.edges.patternedEdges[len(p.edges.patternedEdges)-1] = edge{}
+			p.edges.patternedEdges[len(p.edges.patternedEdges)-1] = edge[V]{}
 			p.edges.patternedEdges = p.edges.patternedEdges[:len(p.edges.patternedEdges)-1]
 		}
 	}
 }
 
-func (p *node) getEdge(l Label) *edge {
+func (p *node[V]) getEdge(l Label) *edge[V] {
 	s := l.String()
 	if len(p.edges.literalEdges) > 0 {
 		x := p.edges.literalEdges
@@ -207,10 +236,10 @@ func (p *node) getEdge(l Label) *edge {
 	return nil
 }
 
-func (p *node) search(l Label) []edge {
+func (p *node[V]) search(l Label) []edge[V] {
 	s := l.String()
 
-	var found []edge
+	var found []edge[V]
 	if len(p.edges.literalEdges) > 0 {
 		x := p.edges.literalEdges
 		i := sort.Search(len(x), func(i int) bool {
@@ -228,19 +257,23 @@ func (p *node) search(l Label) []edge {
 	return found
 }
 
-func (p *node) mergeChild() {
+func (p *node[V]) mergeChild() {
 	if p.size() != 1 {
 		panic("required total size 1")
 	}
 
-	var child *node
+	var child *node[V]
 	if len(p.edges.literalEdges) == 1 {
 		child = p.edges.literalEdges[0].node
 	} else {
 		child = p.edges.patternedEdges[0].node
 	}
 
-	p.prefix = append(p.prefix, child.prefix...)
+	// the child might be shared by other trees, copies everything
+	prefix := make(Key, 0, len(p.prefix)+len(child.prefix))
+	prefix = append(prefix, p.prefix...)
+	p.prefix = append(prefix, child.prefix...)
 	p.leaf = child.leaf
-	p.edges = child.edges
+	p.edges.literalEdges = append([]edge[V](nil), child.edges.literalEdges...)
+	p.edges.patternedEdges = append([]edge[V](nil), child.edges.patternedEdges...)
 }
@@ -14,6 +14,24 @@ func xGlobLabel(s string) Label {
 	return newGlobLabel(s)
 }
 
+func regexpLabel(s string) Label {
+	if len(s) > 1 && s[0] == '^' {
+		label, err := NewRegexpLabel(s)
+		if err != nil {
+			panic(err)
+		}
+		return label
+	}
+	if IsTyped(s) {
+		key, err := NewPatternSliceKey([]string{s})
+		if err != nil {
+			panic(err)
+		}
+		return key[0]
+	}
+	return NewGlobSliceKey([]string{s})[0]
+}
+
 func TestLessLabel(t *testing.T) {
 	type exp struct {
 		x, y string
@@ -40,6 +58,17 @@ func TestLessLabel(t *testing.T) {
 		{"x", "*x", true, xGlobLabel},
 		{"x*", "x", false, stringLabel},
 		{"x*", "x", false, xGlobLabel},
+		{"x", "^x$", true, regexpLabel},
+		{"^x$", "x", false, regexpLabel},
+		{"^x$", "*", true, regexpLabel},
+		{"*", "^x$", false, regexpLabel},
+		{"^x$", "x*", true, regexpLabel},
+		{"**", "^x$", false, regexpLabel},
+		{"^x$", "^y$", true, regexpLabel},
+		{"^x$", "^x$", false, regexpLabel},
+		{"x", "{:int}", true, regexpLabel},
+		{"{:int}", "*", true, regexpLabel},
+		{"*", "{:int}", false, regexpLabel},
 	}
 
 	for i, c := range cases {
@@ -82,7 +111,7 @@ func TestLessKey(t *testing.T) {
 
 	for i, c := range cases {
 		x, y := c.fn(c.x), c.fn(c.y)
-		if lessKey(x, y) != c.ok {
+		if lessKey(x, y) != c.ok || x.Less(y) != c.ok {
 			t.Errorf("bad case %v: %v", i+1, c)
 		}
 	}
@@ -100,9 +129,9 @@ func TestNode_Search(t *testing.T) {
 		{"/v3/*", xGlobLabel},
 	}
 
-	n := new(node)
+	n := new(node[any])
 	for _, c := range input {
-		n.addEdge(edge{
+		n.addEdge(edge[any]{
 			label: c.fn(c.x),
 		})
 	}
@@ -121,7 +150,7 @@ func TestNode_Search(t *testing.T) {
 
 	for i, c := range output {
 		edges := n.search(stringLabel(c.x))
-		sort.Sort(sortEdgeByPattern(edges))
+		sort.Sort(sortEdgeByPattern[any](edges))
 
 		var l []Label
 		for _, e := range edges {
@@ -0,0 +1,135 @@
+package radix
+
+import (
+	"strings"
+)
+
+// Overlapper is implemented by labels which decide overlaps with others by themselves,
+// e.g. a label composed of several fields.
+type Overlapper interface {
+	Overlap(y Label) bool
+}
+
+// Overlap returns if some literal key might be matched by both keys. Globs are compared by
+// their literal prefixes and suffixes before meta characters, regular expressions and types
+// overlap others only if matching their string representations, and labels implementing
+// `Overlapper` decide by themselves.
+func (k Key) Overlap(x Key) bool {
+	type pos struct{ i, j int }
+	visited := make(map[pos]bool)
+
+	var overlap func(i, j int) bool
+	overlap = func(i, j int) bool {
+		if i == len(k) && j == len(x) {
+			return true
+		}
+		if visited[pos{i, j}] {
+			return false
+		}
+		visited[pos{i, j}] = true
+
+		switch {
+		case i < len(k) && k[i].Wildcards():
+			// matches nothing, or takes one more label
+			return overlap(i+1, j) || j < len(x) && overlap(i, j+1)
+		case j < len(x) && x[j].Wildcards():
+			return overlap(i, j+1) || i < len(k) && overlap(i+1, j)
+		case i < len(k) && j < len(x):
+			return overlapLabel(k[i], x[j]) && overlap(i+1, j+1)
+		}
+		return false
+	}
+
+	return overlap(0, 0)
+}
+
+// Cover returns if every literal key matched by x is matched by the key, in which labels are
+// compared by `Label.Match` with string representations, and wildcards are covered by wildcards only.
+func (k Key) Cover(x Key) bool {
+	type pos struct{ i, j int }
+	visited := make(map[pos]bool)
+
+	var cover func(i, j int) bool
+	cover = func(i, j int) bool {
+		if i == len(k) && j == len(x) {
+			return true
+		}
+		if visited[pos{i, j}] {
+			return false
+		}
+		visited[pos{i, j}] = true
+
+		switch {
+		case i < len(k) && k[i].Wildcards():
+			// matches nothing, or takes one more label including wildcards
+			return cover(i+1, j) || j < len(x) && cover(i, j+1)
+		case j < len(x) && x[j].Wildcards():
+			return false
+		case i < len(k) && j < len(x):
+			return coverLabel(k[i], x[j]) && cover(i+1, j+1)
+		}
+		return false
+	}
+
+	return cover(0, 0)
+}
+
+func coverLabel(x, y Label) bool {
+	a, b := x.String(), y.String()
+	switch {
+	case a == b:
+		return true
+	case y.Literal():
+		return x.Match(b)
+	case x.Literal():
+		return false
+	}
+	return x.Match(b)
+}
+
+func overlapLabel(x, y Label) bool {
+	if p, ok := x.(*ParamLabel); ok {
+		x = p.Pattern()
+	}
+	if p, ok := y.(*ParamLabel); ok {
+		y = p.Pattern()
+	}
+
+	a, b := x.String(), y.String()
+	if a == b {
+		return true
+	}
+	if v, ok := x.(Overlapper); ok {
+		return v.Overlap(y)
+	}
+	if v, ok := y.(Overlapper); ok {
+		return v.Overlap(x)
+	}
+
+	switch {
+	case x.Literal():
+		return y.Match(a)
+	case y.Literal():
+		return x.Match(b)
+	case x.Match(b) || y.Match(a):
+		return true
+	case rankLabel(x) == 1 || rankLabel(y) == 1:
+		return false
+	}
+
+	// both are globs, e.g. `a*` and `*b` overlap but `a*` and `b*` don't, others without
+	// meta characters might overlap
+	i, j := strings.IndexAny(a, globMeta), strings.IndexAny(b, globMeta)
+	if i == -1 || j == -1 {
+		return true
+	}
+	if !strings.HasPrefix(a[:i], b[:j]) && !strings.HasPrefix(b[:j], a[:i]) {
+		return false
+	}
+	i, j = strings.LastIndexAny(a, globMeta), strings.LastIndexAny(b, globMeta)
+	return strings.HasSuffix(a[i+1:], b[j+1:]) || strings.HasSuffix(b[j+1:], a[i+1:])
+}
+
+// globMeta are characters which begin or end special sections of globs, e.g. `*`, `?`, `[a-z]`,
+// `{a,b}` and escapes.
+const globMeta = "*?[]{}\\"
@@ -0,0 +1,117 @@
+package radix
+
+import (
+	"testing"
+)
+
+func TestKey_Overlap(t *testing.T) {
+	cases := []struct {
+		x, y string
+		ok   bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/*", "a/b", true},
+		{"a/*", "*/b", true},
+		{"a*/b", "*a/b", true},
+		{"a*/b", "b*/b", false},
+		{"*a/b", "*b/b", false},
+		{"a/**", "a", true},
+		{"a/**", "b/**", false},
+		{"v4/**/x", "v4/*/**/x", true},
+		{"v4/**/x", "v4/a/**", true},
+		{"v4/**/x", "v4/**/y", false},
+		{"**/x", "a/**", true},
+		{"a/*", "a/b/c", false},
+		{"a/{id}", "a/*", true},
+		{"a/{id:int}", "a/*", true},
+		{"a/{id:int}", "a/1", true},
+		{"a/{id:int}", "a/b", false},
+		{"a/{re:^x$}", "a/{id:int}", false},
+	}
+
+	for i, c := range cases {
+		x, err := NewPatternSliceKey(SplitPattern(c.x, "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err := NewPatternSliceKey(SplitPattern(c.y, "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.Overlap(y) != c.ok || y.Overlap(x) != c.ok {
+			t.Errorf("bad case %d: expected %v for %q and %q", i+1, c.ok, c.x, c.y)
+		}
+	}
+}
+
+func TestKey_Cover(t *testing.T) {
+	cases := []struct {
+		x, y string
+		ok   bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/*", "a/b", true},
+		{"a/b", "a/*", false},
+		{"a/*", "a/{id}", true},
+		{"a/{id}", "a/*", true},
+		{"a/*", "a/x*", true},
+		{"a/x*", "a/*", false},
+		{"a/**", "a", true},
+		{"a/**", "a/*/**", true},
+		{"a/*/**", "a/**", false},
+		{"v4/**/x", "v4/*/**/x", true},
+		{"v4/*/**/x", "v4/**/x", false},
+		{"**", "a/**/b", true},
+		{"a/{re:^.*$}", "a/x*", true},
+		{"a/*", "a/{id:int}", true},
+		{"a/{id:int}", "a/*", false},
+	}
+
+	for i, c := range cases {
+		x, err := NewPatternSliceKey(SplitPattern(c.x, "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err := NewPatternSliceKey(SplitPattern(c.y, "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.Cover(y) != c.ok {
+			t.Errorf("bad case %d: expected %v for %q and %q", i+1, c.ok, c.x, c.y)
+		}
+	}
+}
+
+// metaLabel is a glob of other packages, which might have meta characters other than `*`.
+type metaLabel string
+
+func (l metaLabel) String() string      { return string(l) }
+func (l metaLabel) Match(s string) bool { return string(l) == s }
+func (l metaLabel) Literal() bool       { return false }
+func (l metaLabel) Wildcards() bool     { return false }
+
+// disjointLabel overlaps nothing but itself.
+type disjointLabel struct{ metaLabel }
+
+func (l disjointLabel) Overlap(Label) bool { return false }
+
+func TestKey_OverlapMeta(t *testing.T) {
+	cases := []struct {
+		x, y Label
+		ok   bool
+	}{
+		{metaLabel("v?"), metaLabel("{a,b}"), true},
+		{metaLabel("a?"), newGlobLabel("b*"), false},
+		{metaLabel("a?"), newGlobLabel("a*"), true},
+		{metaLabel("[a-c]x"), newGlobLabel("*y"), false},
+		{disjointLabel{"a*"}, newGlobLabel("a*b"), false},
+	}
+
+	for i, c := range cases {
+		x, y := Key{c.x}, Key{c.y}
+		if x.Overlap(y) != c.ok || y.Overlap(x) != c.ok {
+			t.Errorf("bad case %d: expected %v for %q and %q", i+1, c.ok, c.x, c.y)
+		}
+	}
+}
@@ -27,7 +27,7 @@ func TestTree(t *testing.T) {
 	input := randomStringSlice(1000, 20)
 	sort.Strings(input)
 
-	r := New()
+	r := New[int]()
 	for i := 0; i < len(input); i++ {
 		s := input[i]
 		r.Insert(NewCharKey(s), i)
@@ -38,9 +38,9 @@ func TestTree(t *testing.T) {
 	}
 
 	// Check walking in order
-	r.Walk(func(leaf Leaf) bool {
+	r.Walk(func(leaf Leaf[int]) bool {
 		k, v := leaf.Key, leaf.Value
-		i := v.(int)
+		i := v
 		s := input[i]
 
 		if s != k.StringWith("") {
@@ -74,7 +74,7 @@ func TestTree(t *testing.T) {
 }
 
 func TestRoot(t *testing.T) {
-	r := New()
+	r := New[bool]()
 	k := NewCharKey("")
 	_, ok := r.Delete(k)
 	if ok {
@@ -99,7 +99,7 @@ func TestRoot(t *testing.T) {
 }
 
 func TestDelete(t *testing.T) {
-	r := New()
+	r := New[bool]()
 	keys := []string{"", "A", "AB"}
 
 	for _, key := range keys {
@@ -131,7 +131,7 @@ func TestDeletePrefix(t *testing.T) {
 	}
 
 	for i, c := range cases {
-		r := New()
+		r := New[bool]()
 		for _, s := range c.x {
 			r.Insert(NewCharKey(s), true)
 		}
@@ -142,7 +142,7 @@ func TestDeletePrefix(t *testing.T) {
 		}
 
 		var y []string
-		fn := func(leaf Leaf) bool {
+		fn := func(leaf Leaf[bool]) bool {
 			y = append(y, leaf.Key.StringWith(""))
 			return false
 		}
@@ -155,7 +155,7 @@ func TestDeletePrefix(t *testing.T) {
 }
 
 func TestLongestPrefix_Char(t *testing.T) {
-	r := New()
+	r := New[any]()
 
 	keys := []string{
 		"",
@@ -209,7 +209,7 @@ func TestLongestPrefix_Char(t *testing.T) {
 }
 
 func TestLongestPrefix_Glob(t *testing.T) {
-	r := New()
+	r := New[any]()
 
 	keys := []string{
 		"",
@@ -263,7 +263,7 @@ func TestLongestPrefix_Glob(t *testing.T) {
 }
 
 func TestMatch_Glob(t *testing.T) {
-	r := New()
+	r := New[any]()
 
 	keys := []string{
 		"*",
@@ -321,8 +321,50 @@ func TestMatch_Glob(t *testing.T) {
 	}
 }
 
+func TestMatch_Regexp(t *testing.T) {
+	r := New[any]()
+
+	keys := []string{
+		"**",
+		"api/*",
+		"api/v1",
+		"api/{re:^v[0-9]+$}",
+		"api/{re:^v[0-9]+$}/{re:^[a-z.]+$}",
+		"api/v*/*",
+	}
+	for _, k := range keys {
+		x, err := NewPatternKey(k, "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Insert(x, nil)
+	}
+
+	type exp struct {
+		x string
+		y []string
+	}
+	cases := []exp{
+		{"api/v1", []string{"api/v1", "api/{re:^v[0-9]+$}", "api/*", "**"}},
+		{"api/v2", []string{"api/{re:^v[0-9]+$}", "api/*", "**"}},
+		{"api/vx", []string{"api/*", "**"}},
+		{"api/v2/a.b", []string{"api/{re:^v[0-9]+$}/{re:^[a-z.]+$}", "api/v*/*", "**"}},
+		{"api/v2/A", []string{"api/v*/*", "**"}},
+	}
+	for i, c := range cases {
+		x := NewStringKey(c.x, "/")
+		var y []string
+		for _, leaf := range r.Match(x) {
+			y = append(y, leaf.Key.StringWith("/"))
+		}
+		if !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %v: expected %v, got %v", i+1, c.y, y)
+		}
+	}
+}
+
 func TestWalkPrefix(t *testing.T) {
-	r := New()
+	r := New[any]()
 
 	keys := []string{
 		"foobar",
@@ -387,7 +429,7 @@ func TestWalkPrefix(t *testing.T) {
 
 	for _, test := range cases {
 		y := []string{}
-		fn := func(leaf Leaf) bool {
+		fn := func(leaf Leaf[any]) bool {
 			y = append(y, leaf.Key.StringWith(""))
 			return false
 		}
@@ -401,7 +443,7 @@ func TestWalkPrefix(t *testing.T) {
 }
 
 func TestWalkPath(t *testing.T) {
-	r := New()
+	r := New[any]()
 
 	keys := []string{
 		"foo",
@@ -459,7 +501,7 @@ func TestWalkPath(t *testing.T) {
 
 	for _, test := range cases {
 		y := []string{}
-		fn := func(leaf Leaf) bool {
+		fn := func(leaf Leaf[any]) bool {
 			y = append(y, leaf.Key.StringWith(""))
 			return false
 		}
@@ -472,8 +514,94 @@ func TestWalkPath(t *testing.T) {
 	}
 }
 
+func TestTxn(t *testing.T) {
+	r := New[string]()
+	for _, s := range []string{"", "A", "AB", "ABC", "R", "S"} {
+		r.Insert(NewCharKey(s), s)
+	}
+	snapshot := r.Snapshot()
+
+	txn := r.Txn()
+	txn.Insert(NewCharKey("AC"), "AC")
+	txn.Insert(NewCharKey("A"), "a")
+	txn.Delete(NewCharKey("AB"))
+	txn.DeletePrefix(NewCharKey("S"))
+
+	if v, ok := txn.Get(NewCharKey("A")); !ok || v != "a" {
+		t.Fatalf("bad txn value: %v", v)
+	}
+	if v, ok := r.Get(NewCharKey("A")); !ok || v != "A" {
+		t.Fatalf("bad tree value before committing: %v", v)
+	}
+
+	committed := txn.Commit()
+	txn.Insert(NewCharKey("Z"), "Z")
+
+	walk := func(tree *Tree[string]) []string {
+		var y []string
+		tree.Walk(func(leaf Leaf[string]) bool {
+			y = append(y, leaf.Key.StringWith("")+"="+leaf.Value)
+			return false
+		})
+		return y
+	}
+
+	cases := []struct {
+		tree *Tree[string]
+		y    []string
+	}{
+		{snapshot, []string{"=", "A=A", "AB=AB", "ABC=ABC", "R=R", "S=S"}},
+		{r, []string{"=", "A=A", "AB=AB", "ABC=ABC", "R=R", "S=S"}},
+		{committed, []string{"=", "A=a", "ABC=ABC", "AC=AC", "R=R"}},
+		{txn.Commit(), []string{"=", "A=a", "ABC=ABC", "AC=AC", "R=R", "Z=Z"}},
+	}
+	for i, c := range cases {
+		if y := walk(c.tree); !reflect.DeepEqual(y, c.y) {
+			t.Errorf("bad case %v: expected %v, got %v", i+1, c.y, y)
+		}
+		if c.tree.Len() != len(c.y) {
+			t.Errorf("bad case %v: expected length %v, got %v", i+1, len(c.y), c.tree.Len())
+		}
+	}
+}
+
+func TestSnapshot_Parallel(t *testing.T) {
+	input := make(map[string]int)
+	for i, s := range randomStringSlice(1000, 8) {
+		input[s] = i
+	}
+
+	r := New[int]()
+	for s, i := range input {
+		r.Insert(NewCharKey(s), i)
+	}
+	snapshot := r.Snapshot()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for s := range input {
+			r.Delete(NewCharKey(s))
+		}
+	}()
+
+	for s, i := range input {
+		if v, ok := snapshot.Get(NewCharKey(s)); !ok || v != i {
+			t.Fatalf("value mis-match: %v %v", v, i)
+		}
+	}
+	<-done
+
+	if snapshot.Len() != len(input) {
+		t.Fatalf("bad snapshot length: %v", snapshot.Len())
+	}
+	if r.Len() != 0 {
+		t.Fatalf("bad length: %v", r.Len())
+	}
+}
+
 func BenchmarkTree_Insert(b *testing.B) {
-	r := New()
+	r := New[int]()
 	for i := 0; i < b.N; i++ {
 		k := NewCharKey(randomString(128))
 		r.Insert(k, i)
@@ -482,7 +610,7 @@ func BenchmarkTree_Insert(b *testing.B) {
 
 func BenchmarkTree_Get(b *testing.B) {
 	b.StopTimer()
-	r := New()
+	r := New[int]()
 	var keys []Key
 	for i := 0; i < b.N; i++ {
 		k := NewCharKey(randomString(128))
@@ -497,7 +625,7 @@ func BenchmarkTree_Get(b *testing.B) {
 
 func BenchmarkTree_Match(b *testing.B) {
 	b.StopTimer()
-	r := New()
+	r := New[int]()
 	var keys []Key
 	for i := 0; i < b.N; i++ {
 		k := NewCharKey(randomString(128))
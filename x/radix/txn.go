@@ -0,0 +1,299 @@
+package radix
+
+// Txn is a transaction on a tree. Writes clone only the nodes on the
+// changed path, so any Tree committed before (and its readers) are never
+// affected. A Txn is not safe for concurrent use, a single writer is expected.
+type Txn[V any] struct {
+	root *node[V]
+	size int
+
+	// writable tracks nodes created within the transaction,
+	// which can be modified in place.
+	writable map[*node[V]]struct{}
+}
+
+// Txn starts a new transaction based on the tree.
+func (p *Tree[V]) Txn() *Txn[V] {
+	return &Txn[V]{
+		root:     p.root,
+		size:     p.size,
+		writable: make(map[*node[V]]struct{}),
+	}
+}
+
+// Len returns the number of elements in the transaction.
+func (t *Txn[V]) Len() int {
+	return t.size
+}
+
+// Get is used to lookup a specific key within the transaction.
+func (t *Txn[V]) Get(k Key) (V, bool) {
+	tree := &Tree[V]{root: t.root}
+	return tree.Get(k)
+}
+
+// Commit returns a new tree holding all changes in the transaction.
+// The transaction can still be used after committing, but the following
+// writes will never affect the committed tree.
+func (t *Txn[V]) Commit() *Tree[V] {
+	tree := &Tree[V]{
+		root: t.root,
+		size: t.size,
+	}
+	t.writable = make(map[*node[V]]struct{})
+	return tree
+}
+
+// writeNode returns a node which can be modified in place.
+func (t *Txn[V]) writeNode(n *node[V]) *node[V] {
+	if _, ok := t.writable[n]; ok {
+		return n
+	}
+
+	nc := &node[V]{
+		leaf:   n.leaf,
+		prefix: n.prefix,
+	}
+	if len(n.edges.literalEdges) > 0 {
+		nc.edges.literalEdges = make([]edge[V], len(n.edges.literalEdges))
+		copy(nc.edges.literalEdges, n.edges.literalEdges)
+	}
+	if len(n.edges.patternedEdges) > 0 {
+		nc.edges.patternedEdges = make([]edge[V], len(n.edges.patternedEdges))
+		copy(nc.edges.patternedEdges, n.edges.patternedEdges)
+	}
+
+	t.writable[nc] = struct{}{}
+	return nc
+}
+
+// newNode creates a node which can be modified in place.
+func (t *Txn[V]) newNode(n *node[V]) *node[V] {
+	t.writable[n] = struct{}{}
+	return n
+}
+
+// Insert is used to add a new entry or update
+// an existing entry. Returns if updated.
+func (t *Txn[V]) Insert(k Key, v V) (V, bool) {
+	var zero V
+	t.root = t.writeNode(t.root)
+
+	n := t.root
+	search := k
+	for {
+		// Handle key exhaustion
+		if len(search) == 0 {
+			if n.isLeaf() {
+				old := n.leaf.Value
+				n.leaf = &Leaf[V]{
+					Key:   n.leaf.Key,
+					Value: v,
+				}
+				return old, true
+			}
+
+			n.leaf = &Leaf[V]{
+				Key:   k,
+				Value: v,
+			}
+			t.size++
+			return zero, false
+		}
+
+		// Look for the edge
+		parent := n
+		e := n.getEdge(search[0])
+
+		// No edge, create one
+		if e == nil {
+			parent.addEdge(edge[V]{
+				label: search[0],
+				node: t.newNode(&node[V]{
+					leaf: &Leaf[V]{
+						Key:   k,
+						Value: v,
+					},
+					prefix: search,
+				}),
+			})
+			t.size++
+			return zero, false
+		}
+		n = t.writeNode(e.node)
+		e.node = n
+
+		// Determine longest prefix of the search key on match
+		commonPrefix := longestPrefix(search, n.prefix)
+		if commonPrefix == len(n.prefix) {
+			search = search[commonPrefix:]
+			continue
+		}
+
+		// Split the node
+		t.size++
+		child := t.newNode(&node[V]{
+			prefix: search[:commonPrefix],
+		})
+		e.node = child
+
+		// Restore the existing node
+		child.addEdge(edge[V]{
+			label: n.prefix[commonPrefix],
+			node:  n,
+		})
+		n.prefix = n.prefix[commonPrefix:]
+
+		// Create a new leaf node
+		leaf := &Leaf[V]{
+			Key:   k,
+			Value: v,
+		}
+
+		// If the new key is a subset, add to to this node
+		search = search[commonPrefix:]
+		if len(search) == 0 {
+			child.leaf = leaf
+			return zero, false
+		}
+
+		// Create a new edge for the node
+		child.addEdge(edge[V]{
+			label: search[0],
+			node: t.newNode(&node[V]{
+				leaf:   leaf,
+				prefix: search,
+			}),
+		})
+		return zero, false
+	}
+}
+
+// Delete is used to delete a key, returning the previous
+// value and if it was deleted
+func (t *Txn[V]) Delete(k Key) (V, bool) {
+	var zero V
+	if _, ok := t.Get(k); !ok {
+		return zero, false
+	}
+
+	var (
+		parent *node[V]
+		label  Label
+
+		root   = t.writeNode(t.root)
+		n      = root
+		search = k
+	)
+
+	t.root = root
+	for {
+		// Check for key exhaustion
+		if len(search) == 0 {
+			if !n.isLeaf() {
+				break
+			}
+			// Delete the leaf
+			leaf := n.leaf
+			n.leaf = nil
+			t.size--
+
+			// Check if we should delete this node from the parent
+			if parent != nil && n.size() == 0 {
+				parent.delEdge(label)
+			}
+
+			// Check if we should merge this node
+			if n != root && n.size() == 1 {
+				n.mergeChild()
+			}
+
+			// Check if we should merge the parent's other child
+			if parent != nil && parent != root && parent.size() == 1 && !parent.isLeaf() {
+				parent.mergeChild()
+			}
+
+			return leaf.Value, true
+		}
+
+		// Look for an edge
+		parent = n
+		label = search[0]
+		e := n.getEdge(label)
+		if e == nil {
+			break
+		}
+		n = t.writeNode(e.node)
+		e.node = n
+
+		// Consume the search prefix
+		if commonPrefix := longestPrefix(search, n.prefix); commonPrefix == len(n.prefix) {
+			search = search[commonPrefix:]
+		} else {
+			break
+		}
+	}
+	return zero, false
+}
+
+// DeletePrefix is used to delete the subtree under a prefix
+// Returns how many nodes were deleted
+// Use this to delete large subtrees efficiently
+func (t *Txn[V]) DeletePrefix(prefix Key) int {
+	root := t.root
+	t.root = t.writeNode(root)
+	n := t.deletePrefix(nil, t.root, prefix)
+	if n == 0 {
+		t.root = root
+	}
+	return n
+}
+
+// deletePrefix does a recursive deletion
+func (t *Txn[V]) deletePrefix(parent, n *node[V], prefix Key) int {
+	// Check for key exhaustion
+	if len(prefix) == 0 {
+		// Remove the leaf node
+		subTreeSize := 0
+		//recursively walk from all edges of the node to be deleted
+		recursiveWalk(n, func(leaf Leaf[V]) bool {
+			subTreeSize++
+			return false
+		})
+		if n.isLeaf() {
+			n.leaf = nil
+		}
+		// deletes the entire subtree
+		n.edges.literalEdges = nil
+		n.edges.patternedEdges = nil
+
+		// Check if we should merge the parent's other child
+		if parent != nil && parent != t.root && parent.size() == 1 && !parent.isLeaf() {
+			parent.mergeChild()
+		}
+		t.size -= subTreeSize
+		return subTreeSize
+	}
+
+	// Look for an edge
+	e := n.getEdge(prefix[0])
+	if e == nil {
+		return 0
+	}
+	child := e.node
+	commonPrefix := longestPrefix(child.prefix, prefix)
+	if commonPrefix != len(child.prefix) && commonPrefix != len(prefix) {
+		return 0
+	}
+
+	// Consume the search prefix
+	if len(child.prefix) > len(prefix) {
+		prefix = prefix[len(prefix):]
+	} else {
+		prefix = prefix[len(child.prefix):]
+	}
+
+	child = t.writeNode(child)
+	e.node = child
+	return t.deletePrefix(n, child, prefix)
+}
@@ -0,0 +1,167 @@
+package radix
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const typedPrefix = "{:"
+
+// TypeFunc reports if a field is a valid value of a type.
+type TypeFunc func(s string) bool
+
+var (
+	// ErrExistedType resulted from registering a type with an existed name.
+	ErrExistedType = errors.New("existed type")
+
+	types = struct {
+		sync.RWMutex
+		m map[string]TypeFunc
+	}{
+		m: map[string]TypeFunc{
+			"int":   isInt,
+			"uint":  isUint,
+			"hex":   isHex,
+			"uuid":  isUUID,
+			"alpha": isAlpha,
+			"alnum": isAlnum,
+		},
+	}
+)
+
+// RegisterType registers a type which can be used as a constraint of named fields, e.g. `{id:int}`.
+// Builtin types are int, uint, hex, uuid, alpha and alnum.
+func RegisterType(name string, fn TypeFunc) error {
+	if !isIdent(name) || name == "re" {
+		return fmt.Errorf("invalid type name %q", name)
+	}
+	if fn == nil {
+		return fmt.Errorf("nil type function of %q", name)
+	}
+
+	types.Lock()
+	defer types.Unlock()
+
+	if _, ok := types.m[name]; ok {
+		return ErrExistedType
+	}
+	types.m[name] = fn
+	return nil
+}
+
+// unregisterType removes a registered type, labels created from it are still valid.
+func unregisterType(name string) {
+	types.Lock()
+	delete(types.m, name)
+	types.Unlock()
+}
+
+// LookupType returns a registered type.
+func LookupType(name string) (TypeFunc, bool) {
+	types.RLock()
+	fn, ok := types.m[name]
+	types.RUnlock()
+	return fn, ok
+}
+
+// TypedLabel is the label matched by a registered type, written as `{:type}`.
+type TypedLabel struct {
+	typ string
+	fn  TypeFunc
+}
+
+// String implements the `Label` interface.
+func (p *TypedLabel) String() string {
+	return typedPrefix + p.typ + patternSuffix
+}
+
+// Match implements the `Label` interface.
+func (p *TypedLabel) Match(subj string) bool {
+	return p.fn(subj)
+}
+
+// Literal implements the `Label` interface.
+func (p *TypedLabel) Literal() bool {
+	return false
+}
+
+// Wildcards implements the `Label` interface.
+func (p *TypedLabel) Wildcards() bool {
+	return false
+}
+
+// Type returns the type name.
+func (p *TypedLabel) Type() string {
+	return p.typ
+}
+
+// NewTypedLabel creates a label from a registered type.
+func NewTypedLabel(typ string) (*TypedLabel, error) {
+	fn, ok := LookupType(typ)
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	return &TypedLabel{
+		typ: typ,
+		fn:  fn,
+	}, nil
+}
+
+// IsTyped returns if s is written as a typed label, i.e. `{:type}`.
+func IsTyped(s string) bool {
+	return len(s) > len(typedPrefix)+len(patternSuffix) &&
+		strings.HasPrefix(s, typedPrefix) &&
+		strings.HasSuffix(s, patternSuffix)
+}
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+func isUint(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for _, i := range []int{8, 13, 18, 23} {
+		if s[i] != '-' {
+			return false
+		}
+	}
+	return isHex(s[:8]) && isHex(s[9:13]) && isHex(s[14:18]) && isHex(s[19:23]) && isHex(s[24:])
+}
+
+func isAlpha(s string) bool {
+	for _, c := range s {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isAlnum(s string) bool {
+	for _, c := range s {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -4,6 +4,7 @@ package x
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 
 	"github.com/vegertar/mux/x/radix"
 )
@@ -11,6 +12,8 @@ import (
 var (
 	// ErrExistedRoute resulted from adding a handler with an existed route if configured `DisableDupRoute`.
 	ErrExistedRoute = errors.New("existed route")
+	// ErrClosedRegistration resulted from swapping handlers of a closed registration.
+	ErrClosedRegistration = errors.New("closed registration")
 )
 
 type (
@@ -19,32 +22,52 @@ type (
 	CloseFunc func()
 
 	// BreedFunc creates a node under the given label.
-	BreedFunc func(up *Label) Node
+	BreedFunc[H, M any] func(up *Label[H, M]) Node[H, M]
+
+	// Registration is a handle of handlers added by `Router.Register`,
+	// by which handlers can be swapped atomically or unloaded.
+	Registration[H any] struct {
+		closed int32
+		swap   func(h []H, update bool, meta *Meta) error
+		close  func()
+	}
 
 	// Route is a matching sequence for muxing request, e.g. an array of `scheme`, `method`, `path`, etc.
 	Route []radix.Key
 
 	// Router is the mux in which carries a few options and a root node.
-	Router struct {
+	// H and M are types of handlers and middleware respectively.
+	Router[H, M any] struct {
 		// Breed is a factory function to create a new node.
-		Breed BreedFunc
+		Breed BreedFunc[H, M]
 		// DisableDupRoute disallowed to register duplicated routes.
 		DisableDupRoute bool
 
-		mu   sync.RWMutex
-		tree Node
+		mu   sync.Mutex
+		tree atomic.Pointer[Node[H, M]]
 	}
 )
 
 // Routes returns all routes which has associated handlers or middleware.
-func (p *Router) Routes() []Route {
-	var out []Route
+func (p *Router[H, M]) Routes() []Route {
+	out, _ := p.RouteLabels()
+	return out
+}
+
+// RouteLabels returns all routes which has associated handlers or middleware,
+// along with the labels in which the handlers, middleware and metadata are carried.
+func (p *Router[H, M]) RouteLabels() ([]Route, []*Label[H, M]) {
+	var (
+		out    []Route
+		labels []*Label[H, M]
+	)
 
 	for _, leaf := range p.root().Leaves() {
 		for {
 			if len(leaf.Handler) > 0 || len(leaf.Middleware) > 0 {
-				if route := p.route(leaf); len(route) > 0 {
+				if route := leaf.Route(); len(route) > 0 {
 					out = append(out, route)
+					labels = append(labels, leaf)
 				}
 			}
 			up := leaf.Node.Up()
@@ -55,51 +78,86 @@ func (p *Router) Routes() []Route {
 		}
 	}
 
-	return out
+	return out, labels
 }
 
 // Match matches a route and returns all associated labels.
-func (p *Router) Match(r Route) []*Label {
+func (p *Router[H, M]) Match(r Route) []*Label[H, M] {
 	return p.root().Match(r)
 }
 
 // Use associates a route with middleware.
-func (p *Router) Use(r Route, m ...interface{}) (CloseFunc, error) {
-	return p.leaf(r).setupMiddleware(m), nil
+func (p *Router[H, M]) Use(r Route, m ...M) (CloseFunc, error) {
+	return p.UseMeta(r, nil, m...)
+}
+
+// UseMeta associates a route with middleware along with metadata.
+func (p *Router[H, M]) UseMeta(r Route, meta *Meta, m ...M) (CloseFunc, error) {
+	return p.leaf(r).setupMiddleware(m, newMeta(meta)), nil
 }
 
 // Handle associates a route with handlers.
 // If set `DisableDupRoute`, only one handle can be added or `ErrExistedRoute` is returned.
-func (p *Router) Handle(r Route, h ...interface{}) (CloseFunc, error) {
+func (p *Router[H, M]) Handle(r Route, h ...H) (CloseFunc, error) {
+	reg, err := p.Register(r, h...)
+	if err != nil {
+		return nil, err
+	}
+	return reg.Close, nil
+}
+
+// Register associates a route with handlers like `Handle`, but returns a registration
+// by which the handlers can be swapped without a routing gap.
+func (p *Router[H, M]) Register(r Route, h ...H) (*Registration[H], error) {
+	return p.RegisterMeta(r, nil, h...)
+}
+
+// RegisterMeta is like `Register` along with metadata.
+func (p *Router[H, M]) RegisterMeta(r Route, meta *Meta, h ...H) (*Registration[H], error) {
 	leaf := p.leaf(r)
 	if p.DisableDupRoute && len(leaf.Handler) > 0 {
 		return nil, ErrExistedRoute
 	}
-	return leaf.setupHandler(h), nil
+	return leaf.setupHandler(h, newMeta(meta)), nil
 }
 
-func (p *Router) root() Node {
-	p.mu.RLock()
-	root := p.tree
-	p.mu.RUnlock()
-
-	if root == nil {
-		root = p.Breed(nil)
-		p.mu.Lock()
-		if p.tree != nil {
-			root = p.tree
-		} else {
-			p.tree = root
-		}
-		p.mu.Unlock()
+// Swap replaces the registered handlers atomically, concurrent lookups see either the old
+// or the new handlers. It returns `ErrClosedRegistration` if the registration is closed.
+func (r *Registration[H]) Swap(h ...H) error {
+	return r.swap(h, false, nil)
+}
+
+// SwapMeta is like `Swap` along with metadata, a nil meta removes the metadata.
+func (r *Registration[H]) SwapMeta(meta *Meta, h ...H) error {
+	return r.swap(h, true, newMeta(meta))
+}
+
+// Close unloads the registered handlers, subsequent calls do nothing.
+func (r *Registration[H]) Close() {
+	if atomic.CompareAndSwapInt32(&r.closed, 0, 1) {
+		r.close()
 	}
+}
+
+func (p *Router[H, M]) root() Node[H, M] {
+	if root := p.tree.Load(); root != nil {
+		return *root
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
+	if root := p.tree.Load(); root != nil {
+		return *root
+	}
+	root := p.Breed(nil)
+	p.tree.Store(&root)
 	return root
 }
 
-func (p *Router) leaf(r Route) *Label {
+func (p *Router[H, M]) leaf(r Route) *Label[H, M] {
 	var (
-		leaf *Label
+		leaf *Label[H, M]
 		node = p.root()
 	)
 
@@ -113,20 +171,3 @@ func (p *Router) leaf(r Route) *Label {
 
 	return leaf
 }
-
-func (p *Router) route(leaf *Label) Route {
-	var route []radix.Key
-
-	for {
-		route = append(route, leaf.Key)
-		up := leaf.Node.Up()
-		if up == nil {
-			break
-		}
-		leaf = up.Clone()
-	}
-	for i, j := 0, len(route)-1; i < j; i, j = i+1, j-1 {
-		route[i], route[j] = route[j], route[i]
-	}
-	return route
-}
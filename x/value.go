@@ -2,9 +2,13 @@ package x
 
 // Value is a payload of handlers and middleware.
 // Also, an Value carries the current node and a down stream node.
-type Value struct {
-	Handler    []interface{}
-	Middleware []interface{}
-	Node       Node
-	Down       Node
+type Value[H, M any] struct {
+	Handler    []H
+	Middleware []M
+	Node       Node[H, M]
+	Down       Node[H, M]
+	// Meta is metadata of handlers and middleware if registered with.
+	Meta []Meta
+	// Weights are weights of handlers for balancing, see `Weights`.
+	Weights []*Weight
 }